@@ -0,0 +1,217 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// APIKey is a simulation of a legacy grafana instance api key. Like grafana, only a hash of the key is stored.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	Created    time.Time  `json:"created,omitempty"`
+	HashedKey  string     `json:"-"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+func (key APIKey) expired(now time.Time) bool {
+	return key.Expiration != nil && key.Expiration.Before(now)
+}
+
+// CreateAPIKey is a Mock of the grafana api method, that will take a CreateAPIKeyRequest and create a legacy api key.
+// Names must be unique within the org and a positive SecondsToLive sets the expiration.
+func (client *MockClient) CreateAPIKey(request gapi.CreateAPIKeyRequest) (gapi.CreateAPIKeyResponse, error) {
+	if request.SecondsToLive < 0 {
+		return gapi.CreateAPIKeyResponse{}, fmt.Errorf("number of seconds before expiration cannot be negative")
+	}
+	for _, key := range client.APIKeys {
+		if key.Name == request.Name {
+			return gapi.CreateAPIKeyResponse{}, fmt.Errorf("api key name must be unique")
+		}
+	}
+	if err := validateRole(request.Role, InstanceRoles); err != nil {
+		return gapi.CreateAPIKeyResponse{}, err
+	}
+
+	var id int64
+	for _, key := range client.APIKeys {
+		if key.ID > id {
+			id = key.ID
+		}
+	}
+
+	secret := newAPIKey(request.Name, client.orgID())
+	key := APIKey{
+		ID:        id + 1,
+		Name:      request.Name,
+		Role:      request.Role,
+		Created:   client.now(),
+		HashedKey: hashSecret(secret),
+	}
+	if request.SecondsToLive > 0 {
+		expiration := key.Created.Add(time.Duration(request.SecondsToLive) * time.Second)
+		key.Expiration = &expiration
+	}
+	client.APIKeys = append(client.APIKeys, key)
+
+	return gapi.CreateAPIKeyResponse{
+		ID:   key.ID,
+		Name: key.Name,
+		Key:  secret,
+	}, nil
+}
+
+// GetAPIKeys is a Mock of the grafana api method, that will list the legacy api keys. Expired keys are only
+// returned when includeExpired is set.
+func (client *MockClient) GetAPIKeys(includeExpired bool) ([]*gapi.GetAPIKeysResponse, error) {
+	response := make([]*gapi.GetAPIKeysResponse, 0)
+
+	now := client.now()
+	for _, key := range client.APIKeys {
+		if !includeExpired && key.expired(now) {
+			continue
+		}
+		apiKey := &gapi.GetAPIKeysResponse{
+			ID:   key.ID,
+			Name: key.Name,
+			Role: key.Role,
+		}
+		if key.Expiration != nil {
+			apiKey.Expiration = *key.Expiration
+		}
+		response = append(response, apiKey)
+	}
+	return response, nil
+}
+
+// DeleteAPIKey is a Mock of the grafana api method, that will delete the legacy api key with the given id
+func (client *MockClient) DeleteAPIKey(id int64) (gapi.DeleteAPIKeyResponse, error) {
+	for idx, key := range client.APIKeys {
+		if key.ID == id {
+			client.APIKeys = append(client.APIKeys[:idx], client.APIKeys[idx+1:]...)
+			return gapi.DeleteAPIKeyResponse{Message: "API key deleted"}, nil
+		}
+	}
+	return gapi.DeleteAPIKeyResponse{}, fmt.Errorf("api key not found")
+}
+
+// MigrationResult is the summary grafana returns when migrating all legacy api keys to service accounts
+type MigrationResult struct {
+	Total           int      `json:"total"`
+	Migrated        int      `json:"migrated"`
+	Failed          int      `json:"failed"`
+	FailedApikeyIDs []int64  `json:"failedApikeyIDs"`
+	FailedDetails   []string `json:"failedDetails"`
+}
+
+// MigrateAPIKeyToServiceAccount is a Mock of the grafana api method, that will convert the legacy api key with the given id
+// into a service account holding a token with the same secret, and delete the original key
+func (client *MockClient) MigrateAPIKeyToServiceAccount(keyID int64) (*gapi.ServiceAccountDTO, error) {
+	var key *APIKey
+	for idx := range client.APIKeys {
+		if client.APIKeys[idx].ID == keyID {
+			key = &client.APIKeys[idx]
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	for _, token := range client.Tokens {
+		if token.Name == key.Name {
+			return nil, fmt.Errorf("token name must be unique")
+		}
+	}
+
+	sa, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{
+		Name: fmt.Sprintf("sa-autogen-%s", key.Name),
+		Role: key.Role,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Tokens = append(client.Tokens, Token{
+		ID:               client.nextTokenID(),
+		Name:             key.Name,
+		Created:          key.Created,
+		HashedKey:        key.HashedKey,
+		Expiration:       key.Expiration,
+		ServiceAccountID: sa.ID,
+	})
+	client.countServiceAccountTokens(sa.ID)
+	for idx := range client.ServiceAccountsDTO {
+		if client.ServiceAccountsDTO[idx].ID == sa.ID {
+			// grafana names migrated accounts sa-autogen-<name> but includes the org in the login
+			client.ServiceAccountsDTO[idx].Login = fmt.Sprintf("sa-autogen-%d-%s", client.orgID(), key.Name)
+			*sa = client.ServiceAccountsDTO[idx]
+		}
+	}
+
+	if _, err := client.DeleteAPIKey(keyID); err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+// MigrateAPIKeysToServiceAccounts is a Mock of the grafana api method, that will migrate every legacy api key to a service
+// account. Keys that can't be migrated are left in place and reported in the result.
+func (client *MockClient) MigrateAPIKeysToServiceAccounts() (*MigrationResult, error) {
+	keys := make([]APIKey, len(client.APIKeys))
+	copy(keys, client.APIKeys)
+
+	result := &MigrationResult{
+		Total:           len(keys),
+		FailedApikeyIDs: make([]int64, 0),
+		FailedDetails:   make([]string, 0),
+	}
+	for _, key := range keys {
+		if _, err := client.MigrateAPIKeyToServiceAccount(key.ID); err != nil {
+			result.Failed++
+			result.FailedApikeyIDs = append(result.FailedApikeyIDs, key.ID)
+			result.FailedDetails = append(result.FailedDetails, fmt.Sprintf("API key name: %s - Error: %s", key.Name, err))
+			continue
+		}
+		result.Migrated++
+	}
+	return result, nil
+}
+
+// GenerateAPIKeys generates x number of legacy api keys (x specified by count) with an optional prefix and role.
+// if role isn't specified, then a random one will be generated.
+func (client *MockClient) GenerateAPIKeys(count int, prefix, role string) ([]gapi.CreateAPIKeyResponse, error) {
+	var keys []gapi.CreateAPIKeyResponse
+	var name string
+
+	for i := 0; i < count; i++ {
+		if prefix != "" {
+			name = prefix + "-" + StringGenerator(len(client.APIKeys)+1)
+		}
+		key, err := client.GenerateAPIKey(name, role)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// GenerateAPIKey generates a legacy api key with the supplied name and role or generates them randomly
+// if not given
+func (client *MockClient) GenerateAPIKey(name, role string) (gapi.CreateAPIKeyResponse, error) {
+	if name == "" {
+		name = StringGenerator(len(client.APIKeys) + 1)
+	}
+	if role == "" {
+		role = RoleGenerator()
+	}
+	request := gapi.CreateAPIKeyRequest{
+		Name: name,
+		Role: role,
+	}
+	return client.CreateAPIKey(request)
+}
@@ -0,0 +1,246 @@
+package mockgrafana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	t.Run("should return error if name exists", func(t *testing.T) {
+		client := NewClient()
+
+		request := gapi.CreateAPIKeyRequest{
+			Name: StringGenerator(0),
+			Role: "Viewer",
+		}
+		client.CreateAPIKey(request)
+		_, err := client.CreateAPIKey(request)
+
+		if err == nil {
+			t.Errorf("expected error, got none")
+		}
+	})
+
+	t.Run("should return error if seconds to live is negative", func(t *testing.T) {
+		client := NewClient()
+
+		request := gapi.CreateAPIKeyRequest{
+			Name:          StringGenerator(0),
+			Role:          "Viewer",
+			SecondsToLive: -1,
+		}
+		_, err := client.CreateAPIKey(request)
+
+		if err == nil {
+			t.Errorf("expected error, got none")
+		}
+	})
+
+	t.Run("should create key with a secret", func(t *testing.T) {
+		client := NewClient()
+
+		arg := "testName"
+		response, _ := client.CreateAPIKey(gapi.CreateAPIKeyRequest{Name: arg, Role: "Editor"})
+
+		if response.Name != arg {
+			t.Errorf("got %q want %q", response.Name, arg)
+		}
+		if response.Key == "" {
+			t.Errorf("expected a key but got none")
+		}
+		if len(client.APIKeys) != 1 {
+			t.Errorf("want 1 api key, got %d", len(client.APIKeys))
+		}
+	})
+
+	t.Run("should set expiration from seconds to live", func(t *testing.T) {
+		client := NewClient()
+
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+
+		client.CreateAPIKey(gapi.CreateAPIKeyRequest{Name: "expiring", Role: "Viewer", SecondsToLive: 60})
+
+		want := now.Add(time.Minute)
+		got := client.APIKeys[0].Expiration
+
+		if got == nil || !got.Equal(want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	t.Run("should only include expired keys if requested", func(t *testing.T) {
+		client := NewClient()
+
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+
+		client.CreateAPIKey(gapi.CreateAPIKeyRequest{Name: "expiring", Role: "Viewer", SecondsToLive: 60})
+		client.GenerateAPIKeys(2, "", "")
+
+		now = now.Add(2 * time.Minute)
+
+		keys, _ := client.GetAPIKeys(false)
+		if len(keys) != 2 {
+			t.Errorf("got %d keys without expired, want %d", len(keys), 2)
+		}
+
+		keys, _ = client.GetAPIKeys(true)
+		if len(keys) != 3 {
+			t.Errorf("got %d keys with expired, want %d", len(keys), 3)
+		}
+	})
+}
+
+func TestDeleteAPIKey(t *testing.T) {
+	t.Run("should return error if key doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.DeleteAPIKey(3)
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("key should be deleted", func(t *testing.T) {
+		client := NewClient()
+
+		countArg := 10
+		key, _ := client.GenerateAPIKey("", "")
+		client.GenerateAPIKeys(countArg, "", "")
+
+		response, err := client.DeleteAPIKey(key.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if response.Message != "API key deleted" {
+			t.Errorf("got message %q", response.Message)
+		}
+
+		for _, apiKey := range client.APIKeys {
+			if apiKey.ID == key.ID {
+				t.Errorf("expected key %v to be deleted", key.ID)
+			}
+		}
+		if len(client.APIKeys) != countArg {
+			t.Errorf("Expected to have %d key(s) after deletion, but got %d", countArg, len(client.APIKeys))
+		}
+	})
+
+	t.Run("ids should not be reused after deletion", func(t *testing.T) {
+		client := NewClient()
+
+		keys, _ := client.GenerateAPIKeys(3, "", "")
+		client.DeleteAPIKey(keys[0].ID)
+		key, _ := client.GenerateAPIKey("", "")
+
+		for _, existing := range keys[1:] {
+			if existing.ID == key.ID {
+				t.Errorf("expected a fresh id, got %d", key.ID)
+			}
+		}
+	})
+}
+
+func TestMigrateAPIKeyToServiceAccount(t *testing.T) {
+	t.Run("should return error if key doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.MigrateAPIKeyToServiceAccount(3)
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should move the key into a service account token", func(t *testing.T) {
+		client := NewClient()
+
+		key, _ := client.GenerateAPIKey("legacy", "Editor")
+		client.GenerateAPIKeys(2, "", "")
+
+		sa, err := client.MigrateAPIKeyToServiceAccount(key.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if sa.Name != "sa-autogen-legacy" || sa.Role != "Editor" || sa.Tokens != 1 {
+			t.Errorf("got unexpected service account %+v", sa)
+		}
+		if sa.Login != "sa-autogen-1-legacy" || client.ServiceAccountsDTO[0].Login != sa.Login {
+			t.Errorf("got login %q want sa-autogen-1-legacy", sa.Login)
+		}
+		if len(client.Tokens) != 1 || client.Tokens[0].ServiceAccountID != sa.ID || client.Tokens[0].Name != key.Name {
+			t.Errorf("expected a token %q for service account %d, got %+v", key.Name, sa.ID, client.Tokens)
+		}
+		if err := client.Initialize(key.Key, ""); err != nil {
+			t.Errorf("expected migrated secret to authenticate, got %v", err)
+		}
+		if len(client.APIKeys) != 2 {
+			t.Errorf("expected original key to be deleted, got %+v", client.APIKeys)
+		}
+	})
+
+	t.Run("should leave key in place if service account can't be created", func(t *testing.T) {
+		client := NewClient()
+
+		key, _ := client.GenerateAPIKey("legacy", "Viewer")
+		client.GenerateServiceAccount("sa-autogen-legacy", "")
+
+		_, err := client.MigrateAPIKeyToServiceAccount(key.ID)
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+		if len(client.APIKeys) != 1 {
+			t.Errorf("expected key to remain, got %+v", client.APIKeys)
+		}
+	})
+}
+
+func TestMigrateAPIKeysToServiceAccounts(t *testing.T) {
+	t.Run("should migrate every key and report failures", func(t *testing.T) {
+		client := NewClient()
+
+		countArg := 5
+		client.GenerateAPIKeys(countArg, "", "")
+		failing, _ := client.GenerateAPIKey("taken", "")
+		client.GenerateServiceAccount("sa-autogen-taken", "")
+
+		result, _ := client.MigrateAPIKeysToServiceAccounts()
+
+		if result.Total != countArg+1 || result.Migrated != countArg || result.Failed != 1 {
+			t.Errorf("got unexpected result %+v", result)
+		}
+		if len(result.FailedApikeyIDs) != 1 || result.FailedApikeyIDs[0] != failing.ID {
+			t.Errorf("expected key %d to fail, got %v", failing.ID, result.FailedApikeyIDs)
+		}
+		if len(client.APIKeys) != 1 || len(client.Tokens) != countArg {
+			t.Errorf("got %d keys and %d tokens after migration", len(client.APIKeys), len(client.Tokens))
+		}
+	})
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	client := NewClient()
+
+	count := 100
+	for i := 1; i <= count; i++ {
+		_, err := client.GenerateAPIKey("", "")
+		if err != nil {
+			break
+		}
+	}
+
+	want := count
+	got := len(client.APIKeys)
+
+	if got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
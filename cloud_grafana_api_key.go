@@ -0,0 +1,19 @@
+package mockgrafana
+
+import (
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// CreateGrafanaAPIKeyFromCloud is a Mock of the grafana api method, that will create a legacy api key in the grafana
+// instance of the stack with the given slug
+func (client *MockClient) CreateGrafanaAPIKeyFromCloud(stack string, input *gapi.CreateAPIKeyRequest) (*gapi.CreateAPIKeyResponse, error) {
+	s, err := client.activeStackBySlug(stack)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.stackInstance(s).CreateAPIKey(*input)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
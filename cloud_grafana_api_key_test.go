@@ -0,0 +1,39 @@
+package mockgrafana
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestCreateGrafanaAPIKeyFromCloud(t *testing.T) {
+	t.Run("should create api key in the stack instance", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+
+		response, err := client.CreateGrafanaAPIKeyFromCloud("clabs", &gapi.CreateAPIKeyRequest{Name: "deploy", Role: "Editor"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		instance, _ := client.InstanceClient(stack.URL)
+		keys, _ := instance.GetAPIKeys(false)
+		if len(keys) != 1 || keys[0].Name != "deploy" {
+			t.Errorf("got instance api keys %v", keys)
+		}
+		if _, err := instance.LookupToken(response.Key); err != nil {
+			t.Errorf("expected key to authenticate against the instance but got %v", err)
+		}
+		if len(client.APIKeys) != 0 {
+			t.Errorf("expected the cloud client to hold no api keys but found %v", client.APIKeys)
+		}
+	})
+
+	t.Run("should fail for unknown stack", func(t *testing.T) {
+		client := NewClient()
+
+		if _, err := client.CreateGrafanaAPIKeyFromCloud("clabs", &gapi.CreateAPIKeyRequest{Name: "deploy"}); err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// CreateGrafanaServiceAccountFromCloud is a Mock of the grafana api method, that will create a service account in the
+// grafana instance of the stack with the given slug
+func (client *MockClient) CreateGrafanaServiceAccountFromCloud(stack string, input *gapi.CreateServiceAccountRequest) (*gapi.ServiceAccountDTO, error) {
+	s, err := client.activeStackBySlug(stack)
+	if err != nil {
+		return nil, err
+	}
+	return client.stackInstance(s).CreateServiceAccount(*input)
+}
+
+// CreateGrafanaServiceAccountTokenFromCloud is a Mock of the grafana api method, that will create a token for a
+// service account in the grafana instance of the stack with the given slug
+func (client *MockClient) CreateGrafanaServiceAccountTokenFromCloud(stack string, input *gapi.CreateServiceAccountTokenRequest) (*gapi.CreateServiceAccountTokenResponse, error) {
+	s, err := client.activeStackBySlug(stack)
+	if err != nil {
+		return nil, err
+	}
+	return client.stackInstance(s).CreateServiceAccountToken(*input)
+}
+
+// CreateTemporaryStackGrafanaClient is a Mock of the grafana api method, that creates an Admin service account with a
+// token that expires after tempKeyDuration in the stack's grafana instance. It returns the instance's client along
+// with a cleanup func that deletes the service account.
+func (client *MockClient) CreateTemporaryStackGrafanaClient(stackSlug, tempSaPrefix string, tempKeyDuration time.Duration) (tempClient *MockClient, cleanup func() error, err error) {
+	// like gapi, name the account from the wall clock rather than the mock's, which may be frozen
+	name := fmt.Sprintf("%s%d", tempSaPrefix, time.Now().UnixNano())
+
+	sa, err := client.CreateGrafanaServiceAccountFromCloud(stackSlug, &gapi.CreateServiceAccountRequest{
+		Name: name,
+		Role: "Admin",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = client.CreateGrafanaServiceAccountTokenFromCloud(stackSlug, &gapi.CreateServiceAccountTokenRequest{
+		Name:             name,
+		ServiceAccountID: sa.ID,
+		SecondsToLive:    int64(tempKeyDuration.Seconds()),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stack, _ := client.activeStackBySlug(stackSlug)
+	tempClient = client.stackInstance(stack)
+	cleanup = func() error {
+		_, err := tempClient.DeleteServiceAccount(sa.ID)
+		return err
+	}
+	return tempClient, cleanup, nil
+}
@@ -0,0 +1,114 @@
+package mockgrafana
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestCreateGrafanaServiceAccountFromCloud(t *testing.T) {
+	t.Run("should create service account and token in the stack instance", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+
+		sa, err := client.CreateGrafanaServiceAccountFromCloud("clabs", &gapi.CreateServiceAccountRequest{Name: "bootstrap", Role: "Admin"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		token, err := client.CreateGrafanaServiceAccountTokenFromCloud("clabs", &gapi.CreateServiceAccountTokenRequest{Name: "bootstrap", ServiceAccountID: sa.ID})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if len(client.ServiceAccountsDTO) != 0 || len(client.Tokens) != 0 {
+			t.Errorf("expected the cloud client to hold no service accounts but found %v and %v", client.ServiceAccountsDTO, client.Tokens)
+		}
+		instance := client.stackInstances[stack.ID]
+		if len(instance.ServiceAccountsDTO) != 1 || instance.ServiceAccountsDTO[0].Name != "bootstrap" {
+			t.Errorf("got instance service accounts %v", instance.ServiceAccountsDTO)
+		}
+		if _, err := instance.LookupToken(token.Key); err != nil {
+			t.Errorf("expected token to authenticate against the instance but got %v", err)
+		}
+	})
+
+	t.Run("should keep each stack's service accounts separate", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+		client.GenerateStack("celo")
+
+		_, err := client.CreateGrafanaServiceAccountFromCloud("clabs", &gapi.CreateServiceAccountRequest{Name: "bootstrap"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if _, err := client.CreateGrafanaServiceAccountFromCloud("celo", &gapi.CreateServiceAccountRequest{Name: "bootstrap"}); err != nil {
+			t.Errorf("expected the same name to be usable in another stack but got %v", err)
+		}
+	})
+
+	t.Run("should fail for unknown and pending stacks", func(t *testing.T) {
+		client := NewClient()
+		client.StackCreateDelay = time.Hour
+		client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"})
+
+		_, err := client.CreateGrafanaServiceAccountFromCloud("celo", &gapi.CreateServiceAccountRequest{Name: "bootstrap"})
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+		_, err = client.CreateGrafanaServiceAccountFromCloud("clabs", &gapi.CreateServiceAccountRequest{Name: "bootstrap"})
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error but got %v", err)
+		}
+	})
+}
+
+func TestCreateTemporaryStackGrafanaClient(t *testing.T) {
+	t.Run("should create an expiring admin service account and clean it up", func(t *testing.T) {
+		client := NewClient()
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		client.Now = func() time.Time { return now }
+		client.GenerateStack("clabs")
+
+		tempClient, cleanup, err := client.CreateTemporaryStackGrafanaClient("clabs", "temp-", time.Minute)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if len(tempClient.ServiceAccountsDTO) != 1 || tempClient.ServiceAccountsDTO[0].Role != "Admin" {
+			t.Errorf("got service accounts %v", tempClient.ServiceAccountsDTO)
+		}
+		if !strings.HasPrefix(tempClient.ServiceAccountsDTO[0].Name, "temp-") {
+			t.Errorf("got name %q want temp- prefix", tempClient.ServiceAccountsDTO[0].Name)
+		}
+		if len(tempClient.Tokens) != 1 || !tempClient.Tokens[0].Expiration.Equal(now.Add(time.Minute)) {
+			t.Errorf("got tokens %v", tempClient.Tokens)
+		}
+
+		if err := cleanup(); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if len(tempClient.ServiceAccountsDTO) != 0 || len(tempClient.Tokens) != 0 {
+			t.Errorf("expected cleanup to remove the service account and token")
+		}
+	})
+
+	t.Run("should create more than one client with a frozen clock", func(t *testing.T) {
+		client := NewClient()
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		client.Now = func() time.Time { return now }
+		client.GenerateStack("clabs")
+
+		if _, _, err := client.CreateTemporaryStackGrafanaClient("clabs", "temp-", time.Minute); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		tempClient, _, err := client.CreateTemporaryStackGrafanaClient("clabs", "temp-", time.Minute)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if len(tempClient.ServiceAccountsDTO) != 2 {
+			t.Errorf("got %d service accounts want 2", len(tempClient.ServiceAccountsDTO))
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// GetCloudOrg is a Mock of the grafana api method, that will return the org with the given slug or numeric id
+func (client *MockClient) GetCloudOrg(org string) (gapi.CloudOrg, error) {
+	cloudOrg := client.cloudOrg(org)
+	if cloudOrg == nil {
+		return gapi.CloudOrg{}, apiError(http.StatusNotFound, fmt.Sprintf("org %q not found", org))
+	}
+	return *cloudOrg, nil
+}
+
+// CloudOrgStackCount returns how many stacks the org with the given slug or numeric id has. gapi.CloudOrg has no
+// field for it, so it's exposed separately.
+func (client *MockClient) CloudOrgStackCount(org string) (int, error) {
+	cloudOrg := client.cloudOrg(org)
+	if cloudOrg == nil {
+		return 0, apiError(http.StatusNotFound, fmt.Sprintf("org %q not found", org))
+	}
+	stacks, _ := client.Stacks()
+	var count int
+	for _, stack := range stacks.Items {
+		if stack.OrgID == cloudOrg.ID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// cloudOrgs returns the configured orgs, or a single org for the client's OrgID when none are configured
+func (client *MockClient) cloudOrgs() []gapi.CloudOrg {
+	if client.CloudOrgs != nil {
+		return client.CloudOrgs
+	}
+	return []gapi.CloudOrg{{
+		ID:   client.orgID(),
+		Slug: "mockgrafana",
+		Name: "mockgrafana",
+		URL:  "https://grafana.com/orgs/mockgrafana",
+	}}
+}
+
+func (client *MockClient) cloudOrg(org string) *gapi.CloudOrg {
+	orgs := client.cloudOrgs()
+	for idx := range orgs {
+		if orgs[idx].Slug == org || strconv.FormatInt(orgs[idx].ID, 10) == org {
+			return &orgs[idx]
+		}
+	}
+	return nil
+}
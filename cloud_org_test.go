@@ -0,0 +1,97 @@
+package mockgrafana
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestGetCloudOrg(t *testing.T) {
+	t.Run("should default to an org for the client's org id", func(t *testing.T) {
+		client := NewClient()
+		client.OrgID = 42
+
+		org, err := client.GetCloudOrg("42")
+		if err != nil || org.ID != 42 {
+			t.Errorf("got %+v, %v", org, err)
+		}
+	})
+
+	t.Run("should look up configured orgs by slug", func(t *testing.T) {
+		client := NewClient()
+		client.CloudOrgs = []gapi.CloudOrg{{ID: 7, Slug: "clabs", Name: "cLabs"}}
+
+		org, err := client.GetCloudOrg("clabs")
+		if err != nil || org.ID != 7 {
+			t.Errorf("got %+v, %v", org, err)
+		}
+		if _, err := client.GetCloudOrg("celo"); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+	})
+
+	t.Run("should count the org's stacks", func(t *testing.T) {
+		client := NewClient()
+		client.CloudOrgs = []gapi.CloudOrg{{ID: 1, Slug: "clabs", Name: "cLabs"}}
+		client.GenerateStacks(2, "clabs")
+
+		count, err := client.CloudOrgStackCount("clabs")
+		if err != nil || count != 2 {
+			t.Errorf("got %d, %v want 2", count, err)
+		}
+		stacks, _ := client.Stacks()
+		if stacks.Items[0].OrgSlug != "clabs" {
+			t.Errorf("got org slug %q want clabs", stacks.Items[0].OrgSlug)
+		}
+	})
+}
+
+func TestOrgRealms(t *testing.T) {
+	t.Run("should only accept realms for configured orgs in strict mode", func(t *testing.T) {
+		client := NewClient()
+		client.Strict = true
+		client.CloudOrgs = []gapi.CloudOrg{{ID: 7, Slug: "clabs"}}
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("org", "clabs")},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+
+		input.Realms = []gapi.CloudAccessPolicyRealm{NewRealm("org", "7")}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should check realms against configured orgs without strict mode", func(t *testing.T) {
+		client := NewClient()
+		client.CloudOrgs = []gapi.CloudOrg{{ID: 7, Slug: "clabs"}}
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("org", "8")},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+
+	t.Run("should accept any org realm when no orgs are configured", func(t *testing.T) {
+		client := NewClient()
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("org", "clabs")},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+}
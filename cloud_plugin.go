@@ -0,0 +1,170 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// CloudPlugin is a plugin in the catalogue along with the versions that can be installed, oldest first
+type CloudPlugin struct {
+	ID          int
+	Slug        string
+	Name        string
+	Description string
+	Versions    []string
+}
+
+// latestVersion returns the newest version of the plugin
+func (p CloudPlugin) latestVersion() string {
+	return p.Versions[len(p.Versions)-1]
+}
+
+func (p CloudPlugin) hasVersion(version string) bool {
+	for _, v := range p.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPluginCatalogue is the plugin catalogue used when MockClient.PluginCatalogue isn't set
+var DefaultPluginCatalogue = []CloudPlugin{
+	{ID: 1, Slug: "grafana-clock-panel", Name: "Clock", Versions: []string{"2.1.1", "2.1.2", "2.1.3"}},
+	{ID: 2, Slug: "grafana-piechart-panel", Name: "Pie Chart (old)", Versions: []string{"1.6.2", "1.6.4"}},
+	{ID: 3, Slug: "grafana-worldmap-panel", Name: "Worldmap Panel", Versions: []string{"1.0.2", "1.0.3"}},
+	{ID: 4, Slug: "grafana-polystat-panel", Name: "Polystat", Versions: []string{"2.0.4", "2.1.0", "2.1.2"}},
+	{ID: 5, Slug: "grafana-github-datasource", Name: "GitHub", Versions: []string{"1.4.7", "1.5.0"}},
+}
+
+// InstallCloudPlugin is a Mock of the grafana api method, that will install a plugin from the catalogue on an active
+// stack. The latest version is installed when no version is given. Installing a plugin that is already installed
+// changes it to the requested version, so installing the same version again has no effect.
+func (client *MockClient) InstallCloudPlugin(stackSlug string, pluginSlug string, pluginVersion string) (*gapi.CloudPluginInstallation, error) {
+	stack, err := client.activeStackBySlug(stackSlug)
+	if err != nil {
+		return nil, err
+	}
+	plugin := client.catalogueEntry(pluginSlug)
+	if plugin == nil {
+		return nil, apiError(http.StatusNotFound, fmt.Sprintf("plugin %q not found", pluginSlug))
+	}
+
+	version := pluginVersion
+	if version == "" || version == "latest" {
+		version = plugin.latestVersion()
+	}
+	if !plugin.hasVersion(version) {
+		return nil, apiError(http.StatusBadRequest, fmt.Sprintf("version %q of plugin %q not found", pluginVersion, pluginSlug))
+	}
+
+	if installation := client.cloudPluginInstallation(stack, pluginSlug); installation != nil {
+		installation.Version = version
+		result := *installation
+		return &result, nil
+	}
+
+	var id int
+	for _, installation := range client.CloudPluginInstallations {
+		if installation.ID > id {
+			id = installation.ID
+		}
+	}
+	installation := &gapi.CloudPluginInstallation{
+		ID:           id + 1,
+		InstanceID:   int(stack.ID),
+		InstanceURL:  stack.URL,
+		InstanceSlug: stack.Slug,
+		PluginID:     plugin.ID,
+		PluginSlug:   plugin.Slug,
+		PluginName:   plugin.Name,
+		Version:      version,
+	}
+	client.CloudPluginInstallations = append(client.CloudPluginInstallations, installation)
+
+	result := *installation
+	return &result, nil
+}
+
+// UninstallCloudPlugin is a Mock of the grafana api method, that will uninstall a plugin from an active stack
+func (client *MockClient) UninstallCloudPlugin(stackSlug string, pluginSlug string) error {
+	stack, err := client.activeStackBySlug(stackSlug)
+	if err != nil {
+		return err
+	}
+	for idx, installation := range client.CloudPluginInstallations {
+		if int64(installation.InstanceID) == stack.ID && installation.PluginSlug == pluginSlug {
+			client.CloudPluginInstallations = append(client.CloudPluginInstallations[:idx], client.CloudPluginInstallations[idx+1:]...)
+			return nil
+		}
+	}
+	return apiError(http.StatusNotFound, fmt.Sprintf("plugin %q is not installed", pluginSlug))
+}
+
+// IsCloudPluginInstalled is a Mock of the grafana api method, that reports whether a plugin is installed on a stack.
+// Like gapi, a stack that doesn't exist reports the plugin as not installed.
+func (client *MockClient) IsCloudPluginInstalled(stackSlug string, pluginSlug string) (bool, error) {
+	_, err := client.GetCloudPluginInstallation(stackSlug, pluginSlug)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), fmt.Sprintf("status: %d,", http.StatusNotFound)) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetCloudPluginInstallation is a Mock of the grafana api method, that will return the installation of a plugin on
+// a stack
+func (client *MockClient) GetCloudPluginInstallation(stackSlug string, pluginSlug string) (*gapi.CloudPluginInstallation, error) {
+	stack, err := client.activeStackBySlug(stackSlug)
+	if err != nil {
+		return nil, err
+	}
+	installation := client.cloudPluginInstallation(stack, pluginSlug)
+	if installation == nil {
+		return nil, apiError(http.StatusNotFound, fmt.Sprintf("plugin %q is not installed", pluginSlug))
+	}
+	result := *installation
+	return &result, nil
+}
+
+// PluginBySlug is a Mock of the grafana api method, that will return the latest version of a catalogue plugin
+func (client *MockClient) PluginBySlug(slug string) (*gapi.Plugin, error) {
+	plugin := client.catalogueEntry(slug)
+	if plugin == nil {
+		return nil, apiError(http.StatusNotFound, fmt.Sprintf("plugin %q not found", slug))
+	}
+	return &gapi.Plugin{
+		ID:          plugin.ID,
+		Name:        plugin.Name,
+		Slug:        plugin.Slug,
+		Version:     plugin.latestVersion(),
+		Description: plugin.Description,
+	}, nil
+}
+
+func (client *MockClient) catalogueEntry(slug string) *CloudPlugin {
+	catalogue := client.PluginCatalogue
+	if catalogue == nil {
+		catalogue = DefaultPluginCatalogue
+	}
+	for idx := range catalogue {
+		if catalogue[idx].Slug == slug && len(catalogue[idx].Versions) > 0 {
+			return &catalogue[idx]
+		}
+	}
+	return nil
+}
+
+func (client *MockClient) cloudPluginInstallation(stack *gapi.Stack, pluginSlug string) *gapi.CloudPluginInstallation {
+	for _, installation := range client.CloudPluginInstallations {
+		if int64(installation.InstanceID) == stack.ID && installation.PluginSlug == pluginSlug {
+			return installation
+		}
+	}
+	return nil
+}
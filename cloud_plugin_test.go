@@ -0,0 +1,118 @@
+package mockgrafana
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInstallCloudPlugin(t *testing.T) {
+	t.Run("should install the latest version when none is given", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+
+		installation, err := client.InstallCloudPlugin("clabs", "grafana-clock-panel", "")
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if installation.Version != "2.1.3" || installation.PluginName != "Clock" {
+			t.Errorf("got %+v", installation)
+		}
+		if int64(installation.InstanceID) != stack.ID || installation.InstanceURL != stack.URL {
+			t.Errorf("got %+v want stack %d", installation, stack.ID)
+		}
+	})
+
+	t.Run("should be idempotent for the same version", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+
+		first, _ := client.InstallCloudPlugin("clabs", "grafana-clock-panel", "2.1.2")
+		second, err := client.InstallCloudPlugin("clabs", "grafana-clock-panel", "2.1.2")
+
+		if err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if *first != *second || len(client.CloudPluginInstallations) != 1 {
+			t.Errorf("expected a single unchanged installation but got %+v", client.CloudPluginInstallations)
+		}
+	})
+
+	t.Run("should upgrade to a different version", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+		client.InstallCloudPlugin("clabs", "grafana-clock-panel", "2.1.1")
+
+		installation, err := client.InstallCloudPlugin("clabs", "grafana-clock-panel", "2.1.3")
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		got, _ := client.GetCloudPluginInstallation("clabs", "grafana-clock-panel")
+		if installation.Version != "2.1.3" || got.Version != "2.1.3" || len(client.CloudPluginInstallations) != 1 {
+			t.Errorf("got %+v", client.CloudPluginInstallations)
+		}
+	})
+
+	t.Run("should not install unknown plugins or versions", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+
+		if _, err := client.InstallCloudPlugin("clabs", "grafana-unknown-panel", ""); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+		if _, err := client.InstallCloudPlugin("clabs", "grafana-clock-panel", "9.9.9"); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+
+	t.Run("should use a configured catalogue", func(t *testing.T) {
+		client := NewClient()
+		client.PluginCatalogue = []CloudPlugin{{ID: 1, Slug: "celo-panel", Name: "Celo", Versions: []string{"0.1.0"}}}
+		client.GenerateStack("clabs")
+
+		if _, err := client.InstallCloudPlugin("clabs", "celo-panel", "0.1.0"); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if _, err := client.InstallCloudPlugin("clabs", "grafana-clock-panel", ""); err == nil {
+			t.Errorf("expected plugin outside the catalogue to fail")
+		}
+	})
+}
+
+func TestUninstallCloudPlugin(t *testing.T) {
+	t.Run("should uninstall installed plugin", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+		client.InstallCloudPlugin("clabs", "grafana-clock-panel", "")
+
+		if err := client.UninstallCloudPlugin("clabs", "grafana-clock-panel"); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if installed, _ := client.IsCloudPluginInstalled("clabs", "grafana-clock-panel"); installed {
+			t.Errorf("expected plugin to be uninstalled")
+		}
+		if err := client.UninstallCloudPlugin("clabs", "grafana-clock-panel"); err == nil {
+			t.Errorf("expected error uninstalling twice but got none")
+		}
+	})
+}
+
+func TestIsCloudPluginInstalled(t *testing.T) {
+	t.Run("should report installations per stack", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+		client.GenerateStack("celo")
+		client.InstallCloudPlugin("clabs", "grafana-clock-panel", "")
+
+		if installed, err := client.IsCloudPluginInstalled("clabs", "grafana-clock-panel"); !installed || err != nil {
+			t.Errorf("got %v, %v want installed", installed, err)
+		}
+		if installed, err := client.IsCloudPluginInstalled("celo", "grafana-clock-panel"); installed || err != nil {
+			t.Errorf("got %v, %v want not installed", installed, err)
+		}
+		if installed, err := client.IsCloudPluginInstalled("unknown", "grafana-clock-panel"); installed || err != nil {
+			t.Errorf("got %v, %v want not installed", installed, err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// DefaultCloudRegions are the regions used when MockClient.CloudRegions isn't set
+var DefaultCloudRegions = []gapi.CloudRegion{
+	newCloudRegion(1, "us", "United States"),
+	newCloudRegion(2, "us-azure", "United States (Azure)"),
+	newCloudRegion(3, "eu", "Europe"),
+	newCloudRegion(4, "au", "Australia"),
+	newCloudRegion(5, "prod-ap-south-0", "India"),
+	newCloudRegion(6, "prod-ap-southeast-0", "Singapore"),
+	newCloudRegion(7, "prod-gb-south-0", "United Kingdom"),
+	newCloudRegion(8, "prod-sa-east-0", "Brazil"),
+}
+
+// newCloudRegion returns a public region with cluster urls derived from its slug
+func newCloudRegion(id int, slug, name string) gapi.CloudRegion {
+	cluster := fmt.Sprintf("prod-%s", slug)
+	return gapi.CloudRegion{
+		ID:          id,
+		Status:      "active",
+		Slug:        slug,
+		Name:        name,
+		Description: name,
+		Visibility:  "public",
+
+		HGClusterID:           id,
+		HGClusterSlug:         cluster,
+		HGClusterName:         cluster,
+		HGClusterURL:          fmt.Sprintf("https://hg-api-%s.grafana.net", cluster),
+		HMPromClusterID:       id,
+		HMPromClusterSlug:     cluster,
+		HMPromClusterName:     cluster,
+		HMPromClusterURL:      fmt.Sprintf("https://prometheus-%s.grafana.net", cluster),
+		HMGraphiteClusterID:   id,
+		HMGraphiteClusterSlug: cluster,
+		HMGraphiteClusterName: cluster,
+		HMGraphiteClusterURL:  fmt.Sprintf("https://graphite-%s.grafana.net", cluster),
+		HLClusterID:           id,
+		HLClusterSlug:         cluster,
+		HLClusterName:         cluster,
+		HLClusterURL:          fmt.Sprintf("https://logs-%s.grafana.net", cluster),
+		AMClusterID:           id,
+		AMClusterSlug:         cluster,
+		AMClusterName:         cluster,
+		AMClusterURL:          fmt.Sprintf("https://alertmanager-%s.grafana.net", cluster),
+		HTClusterID:           id,
+		HTClusterSlug:         cluster,
+		HTClusterName:         cluster,
+		HTClusterURL:          fmt.Sprintf("https://tempo-%s.grafana.net", cluster),
+	}
+}
+
+// GetCloudRegions is a Mock of the grafana api method, that will list the regions stacks can be created in
+func (client *MockClient) GetCloudRegions() (gapi.CloudRegionsResponse, error) {
+	regions := gapi.CloudRegionsResponse{}
+	regions.Items = append(regions.Items, client.cloudRegions()...)
+	return regions, nil
+}
+
+// GetCloudRegionBySlug is a Mock of the grafana api method, that will return the region with the given slug or
+// numeric id
+func (client *MockClient) GetCloudRegionBySlug(slug string) (gapi.CloudRegion, error) {
+	region := client.cloudRegion(slug)
+	if region == nil {
+		return gapi.CloudRegion{}, apiError(http.StatusNotFound, fmt.Sprintf("region %q not found", slug))
+	}
+	return *region, nil
+}
+
+func (client *MockClient) cloudRegions() []gapi.CloudRegion {
+	if client.CloudRegions == nil {
+		return DefaultCloudRegions
+	}
+	return client.CloudRegions
+}
+
+func (client *MockClient) cloudRegion(slug string) *gapi.CloudRegion {
+	regions := client.cloudRegions()
+	for idx := range regions {
+		if regions[idx].Slug == slug || strconv.Itoa(regions[idx].ID) == slug {
+			return &regions[idx]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package mockgrafana
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestGetCloudRegions(t *testing.T) {
+	t.Run("should list the default regions", func(t *testing.T) {
+		client := NewClient()
+
+		regions, _ := client.GetCloudRegions()
+		if len(regions.Items) != len(DefaultCloudRegions) {
+			t.Errorf("got %d regions want %d", len(regions.Items), len(DefaultCloudRegions))
+		}
+	})
+
+	t.Run("should look up region by slug or id", func(t *testing.T) {
+		client := NewClient()
+
+		region, err := client.GetCloudRegionBySlug("eu")
+		if err != nil || region.Slug != "eu" || region.HMPromClusterURL == "" {
+			t.Errorf("got %+v, %v", region, err)
+		}
+		byID, err := client.GetCloudRegionBySlug("3")
+		if err != nil || byID.Slug != "eu" {
+			t.Errorf("got %+v, %v", byID, err)
+		}
+		if _, err := client.GetCloudRegionBySlug("moon"); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+	})
+
+	t.Run("should only create stacks in configured regions", func(t *testing.T) {
+		client := NewClient()
+		client.CloudRegions = []gapi.CloudRegion{newCloudRegion(1, "moon", "Moon")}
+
+		id, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs", Region: "moon"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		stack, _ := client.StackByID(id)
+		if stack.RegionSlug != "moon" || stack.HmInstancePromURL != client.CloudRegions[0].HMPromClusterURL {
+			t.Errorf("got %+v", stack)
+		}
+		if _, err := client.NewStack(&gapi.CreateStackInput{Name: "celo", Slug: "celo", Region: "eu"}); err == nil {
+			t.Errorf("expected error for region outside the configured regions")
+		}
+	})
+}
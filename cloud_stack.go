@@ -0,0 +1,398 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// Stack statuses, stacks move from pending to active once created and from deleting to deleted once deleted
+const (
+	StackStatusPending  = "pending"
+	StackStatusActive   = "active"
+	StackStatusDeleting = "deleting"
+	StackStatusDeleted  = "deleted"
+)
+
+// stackSlugPattern is the format grafana cloud requires for stack slugs
+var stackSlugPattern = regexp.MustCompile(`^[a-z][a-z0-9]{0,28}$`)
+
+// Stacks is a Mock of the grafana api method, that will list all stacks that haven't been deleted
+func (client *MockClient) Stacks() (gapi.StackItems, error) {
+	client.refreshStacks()
+	stacks := gapi.StackItems{}
+	for _, stack := range client.StackItems {
+		if stack.Status != StackStatusDeleted {
+			stacks.Items = append(stacks.Items, stack)
+		}
+	}
+	return stacks, nil
+}
+
+// StackBySlug is a Mock of the grafana api method, that will return the stack with the given slug
+func (client *MockClient) StackBySlug(slug string) (gapi.Stack, error) {
+	client.refreshStacks()
+	stack := client.stackBySlug(slug)
+	if stack == nil {
+		return gapi.Stack{}, apiError(http.StatusNotFound, fmt.Sprintf("stack %q not found", slug))
+	}
+	return *stack, nil
+}
+
+// StackByID is a Mock of the grafana api method, that will return the stack with the given id
+func (client *MockClient) StackByID(id int64) (gapi.Stack, error) {
+	client.refreshStacks()
+	stack := client.stackByID(id)
+	if stack == nil {
+		return gapi.Stack{}, apiError(http.StatusNotFound, fmt.Sprintf("stack %d not found", id))
+	}
+	return *stack, nil
+}
+
+// NewStack is a Mock of the grafana api method, that will create a stack from a CreateStackInput and return its id.
+// The region defaults to us and the url, hosted metrics, logs, traces and alerting instances are derived from the
+// slug and the region's clusters. The stack is pending until StackCreateDelay has passed.
+func (client *MockClient) NewStack(input *gapi.CreateStackInput) (int64, error) {
+	client.refreshStacks()
+	regionSlug := input.Region
+	if regionSlug == "" {
+		regionSlug = "us"
+	}
+	region := client.cloudRegion(regionSlug)
+	if region == nil {
+		return 0, apiError(http.StatusBadRequest, fmt.Sprintf("invalid region %q", regionSlug))
+	}
+	if input.Name == "" {
+		return 0, apiError(http.StatusBadRequest, "name is required")
+	}
+	if err := client.validateStackSlug(input.Slug, 0); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	for _, stack := range client.StackItems {
+		if stack.ID > id {
+			id = stack.ID
+		}
+	}
+	id++
+
+	now := client.now()
+	stack := &gapi.Stack{
+		ID:          id,
+		OrgID:       client.orgID(),
+		Name:        input.Name,
+		Slug:        input.Slug,
+		URL:         input.URL,
+		Description: input.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		RegionID:    region.ID,
+		RegionSlug:  region.Slug,
+		ClusterID:   region.HGClusterID,
+		ClusterSlug: region.HGClusterSlug,
+		ClusterName: region.HGClusterName,
+
+		HmInstancePromID:       int(100000 + id),
+		HmInstancePromName:     fmt.Sprintf("%s-prom", input.Slug),
+		HmInstancePromURL:      region.HMPromClusterURL,
+		HmInstanceGraphiteID:   int(200000 + id),
+		HmInstanceGraphiteName: fmt.Sprintf("%s-graphite", input.Slug),
+		HmInstanceGraphiteURL:  region.HMGraphiteClusterURL,
+		HlInstanceID:           int(300000 + id),
+		HlInstanceName:         fmt.Sprintf("%s-logs", input.Slug),
+		HlInstanceURL:          region.HLClusterURL,
+		HtInstanceID:           int(400000 + id),
+		HtInstanceName:         fmt.Sprintf("%s-traces", input.Slug),
+		HtInstanceURL:          region.HTClusterURL,
+		AmInstanceID:           int(500000 + id),
+		AmInstanceName:         fmt.Sprintf("%s-alerts", input.Slug),
+		AmInstanceURL:          region.AMClusterURL,
+	}
+	if stack.URL == "" {
+		stack.URL = defaultStackURL(input.Slug)
+	}
+	if org := client.cloudOrg(strconv.FormatInt(stack.OrgID, 10)); org != nil {
+		stack.OrgSlug = org.Slug
+		stack.OrgName = org.Name
+	}
+
+	client.StackItems = append(client.StackItems, stack)
+	client.transitionStack(stack, StackStatusPending, StackStatusActive, client.StackCreateDelay)
+	return id, nil
+}
+
+// UpdateStack is a Mock of the grafana api method, that will update the name, slug and description of a stack.
+// Changing the slug of a stack on its default url moves the url along with it. Only active stacks can be updated.
+func (client *MockClient) UpdateStack(id int64, input *gapi.UpdateStackInput) error {
+	client.refreshStacks()
+	stack := client.stackByID(id)
+	if stack == nil || stack.Status == StackStatusDeleted {
+		return apiError(http.StatusNotFound, fmt.Sprintf("stack %d not found", id))
+	}
+	if err := checkStackActive(stack); err != nil {
+		return err
+	}
+
+	if input.Slug != "" && input.Slug != stack.Slug {
+		if err := client.validateStackSlug(input.Slug, id); err != nil {
+			return err
+		}
+		if stack.URL == defaultStackURL(stack.Slug) {
+			stack.URL = defaultStackURL(input.Slug)
+		}
+		stack.Slug = input.Slug
+	}
+	if input.Name != "" {
+		stack.Name = input.Name
+	}
+	if input.Description != "" {
+		stack.Description = input.Description
+	}
+	stack.UpdatedAt = client.now()
+	return nil
+}
+
+// DeleteStack is a Mock of the grafana api method, that will delete the stack with the given slug. The stack is
+// deleting until StackDeleteDelay has passed, after which it is deleted and its slug can be reused.
+func (client *MockClient) DeleteStack(stackSlug string) error {
+	client.refreshStacks()
+	stack := client.stackBySlug(stackSlug)
+	if stack == nil || stack.Status == StackStatusDeleted {
+		return apiError(http.StatusNotFound, fmt.Sprintf("stack %q not found", stackSlug))
+	}
+	if stack.Status == StackStatusDeleting {
+		return apiError(http.StatusConflict, fmt.Sprintf("stack %q is already being deleted", stackSlug))
+	}
+	client.transitionStack(stack, StackStatusDeleting, StackStatusDeleted, client.StackDeleteDelay)
+	return nil
+}
+
+// SetStackStatus moves the stack with the given id straight to a status, cancelling any transition in progress.
+// It lets tests drive the stack lifecycle without waiting on the clock.
+func (client *MockClient) SetStackStatus(id int64, status string) error {
+	switch status {
+	case StackStatusPending, StackStatusActive, StackStatusDeleting, StackStatusDeleted:
+	default:
+		return fmt.Errorf("invalid stack status %q", status)
+	}
+	stack := client.stackByID(id)
+	if stack == nil {
+		return fmt.Errorf("stack not found")
+	}
+	delete(client.stackTransitions, stack)
+	setStackStatus(stack, status)
+	return nil
+}
+
+// stackTransition is a status a stack will move to once the mock's clock reaches at
+type stackTransition struct {
+	status string
+	at     time.Time
+}
+
+// transitionStack sets the stack's status and schedules the move to next after delay, making it straight away
+// when there is no delay
+func (client *MockClient) transitionStack(stack *gapi.Stack, status, next string, delay time.Duration) {
+	if delay <= 0 {
+		delete(client.stackTransitions, stack)
+		setStackStatus(stack, next)
+		return
+	}
+	if client.stackTransitions == nil {
+		client.stackTransitions = make(map[*gapi.Stack]stackTransition)
+	}
+	setStackStatus(stack, status)
+	client.stackTransitions[stack] = stackTransition{status: next, at: client.now().Add(delay)}
+}
+
+// refreshStacks completes the stack transitions that are due
+func (client *MockClient) refreshStacks() {
+	now := client.now()
+	for stack, transition := range client.stackTransitions {
+		if !now.Before(transition.at) {
+			setStackStatus(stack, transition.status)
+			delete(client.stackTransitions, stack)
+		}
+	}
+}
+
+// activeStackBySlug returns the stack with the given slug, failing if it doesn't exist or isn't active
+func (client *MockClient) activeStackBySlug(slug string) (*gapi.Stack, error) {
+	client.refreshStacks()
+	stack := client.stackBySlug(slug)
+	if stack == nil || stack.Status == StackStatusDeleted {
+		return nil, apiError(http.StatusNotFound, fmt.Sprintf("stack %q not found", slug))
+	}
+	if err := checkStackActive(stack); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+func checkStackActive(stack *gapi.Stack) error {
+	if stack.Status != StackStatusActive {
+		return apiError(http.StatusConflict, fmt.Sprintf("stack %q is %s", stack.Slug, stack.Status))
+	}
+	return nil
+}
+
+// setStackStatus sets the status of a stack and its hosted instances
+func setStackStatus(stack *gapi.Stack, status string) {
+	stack.Status = status
+	stack.HmInstancePromStatus = status
+	stack.HmInstanceGraphiteStatus = status
+	stack.HlInstanceStatus = status
+	stack.HtInstanceStatus = status
+	stack.AmInstanceStatus = status
+}
+
+// GenerateStacks generates x number of stacks (x specified by count) with an optional slug prefix. When a prefix is
+// given each slug is the prefix followed by the lowest number that isn't in use, as slugs can't contain dashes.
+func (client *MockClient) GenerateStacks(count int, prefix string) ([]*gapi.Stack, error) {
+	var stacks []*gapi.Stack
+	var slug string
+
+	// the suffix never exceeds the number of stacks there will be, so checking the longest one up front covers them all
+	if longest := fmt.Sprintf("%s%d", prefix, len(client.StackItems)+count); prefix != "" && !stackSlugPattern.MatchString(longest) {
+		return nil, apiError(http.StatusBadRequest, fmt.Sprintf("invalid slug prefix %q: slugs must start with a letter, contain only lowercase letters and digits and be at most 29 characters", prefix))
+	}
+
+	client.refreshStacks()
+	for i := 0; i < count; i++ {
+		if prefix != "" {
+			for n := 1; ; n++ {
+				slug = fmt.Sprintf("%s%d", prefix, n)
+				if !client.stackSlugTaken(slug, 0) {
+					break
+				}
+			}
+		}
+		stack, err := client.GenerateStack(slug)
+		if err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+// GenerateStack generates a stack in a random region with the supplied slug, or a random one if not given
+func (client *MockClient) GenerateStack(slug string) (*gapi.Stack, error) {
+	if slug == "" {
+		rand.Seed(time.Now().UnixNano() + int64(len(client.StackItems)))
+		slug = fmt.Sprintf("stack%d%d", rand.Intn(99999), rand.Intn(99999))
+	}
+	regions := client.cloudRegions()
+	id, err := client.NewStack(&gapi.CreateStackInput{
+		Name:   slug,
+		Slug:   slug,
+		Region: regions[rand.Intn(len(regions))].Slug,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client.stackByID(id), nil
+}
+
+// GenerateRealm returns a stack realm for one of the existing stacks, or an org realm for the client's org when
+// there are no stacks
+func (client *MockClient) GenerateRealm() gapi.CloudAccessPolicyRealm {
+	stacks, _ := client.Stacks()
+	if len(stacks.Items) == 0 {
+		return NewRealm("org", strconv.FormatInt(client.orgID(), 10))
+	}
+	stack := stacks.Items[rand.Intn(len(stacks.Items))]
+	return NewRealm("stack", strconv.FormatInt(stack.ID, 10))
+}
+
+// InstanceClient returns the client holding the grafana instance state of the active stack served at stackURL, such
+// as its service accounts, tokens and legacy api keys
+func (client *MockClient) InstanceClient(stackURL string) (*MockClient, error) {
+	client.refreshStacks()
+	url := strings.TrimSuffix(strings.ToLower(stackURL), "/")
+	for _, stack := range client.StackItems {
+		if stack.Status == StackStatusDeleted || strings.TrimSuffix(strings.ToLower(stack.URL), "/") != url {
+			continue
+		}
+		if err := checkStackActive(stack); err != nil {
+			return nil, err
+		}
+		return client.stackInstance(stack), nil
+	}
+	return nil, apiError(http.StatusNotFound, fmt.Sprintf("no stack found for %q", stackURL))
+}
+
+// stackInstance returns the client holding the grafana instance state of a stack, creating it on first use. The
+// instance shares the cloud client's clock and starts with its strictness and quotas.
+func (client *MockClient) stackInstance(stack *gapi.Stack) *MockClient {
+	if instance, ok := client.stackInstances[stack.ID]; ok {
+		return instance
+	}
+	if client.stackInstances == nil {
+		client.stackInstances = make(map[int64]*MockClient)
+	}
+	instance := NewClient()
+	instance.Now = client.now
+	instance.Strict = client.Strict
+	instance.Quotas = client.Quotas
+	client.stackInstances[stack.ID] = instance
+	return instance
+}
+
+// stackBySlug returns the stack using the slug, preferring one that hasn't been deleted as deleted stacks free
+// up their slug
+func (client *MockClient) stackBySlug(slug string) *gapi.Stack {
+	var deleted *gapi.Stack
+	for _, stack := range client.StackItems {
+		if stack.Slug != slug {
+			continue
+		}
+		if stack.Status != StackStatusDeleted {
+			return stack
+		}
+		deleted = stack
+	}
+	return deleted
+}
+
+func (client *MockClient) stackByID(id int64) *gapi.Stack {
+	for _, stack := range client.StackItems {
+		if stack.ID == id {
+			return stack
+		}
+	}
+	return nil
+}
+
+// validateStackSlug checks the slug format and that no stack other than the one with the given id uses it
+func (client *MockClient) validateStackSlug(slug string, id int64) error {
+	if !stackSlugPattern.MatchString(slug) {
+		return apiError(http.StatusBadRequest, fmt.Sprintf("invalid slug %q: must start with a letter and contain only lowercase letters and digits", slug))
+	}
+	if client.stackSlugTaken(slug, id) {
+		return apiError(http.StatusConflict, fmt.Sprintf("stack %q already exists", slug))
+	}
+	return nil
+}
+
+// stackSlugTaken reports whether a stack other than the one with the given id uses the slug. Deleted stacks free up
+// their slug.
+func (client *MockClient) stackSlugTaken(slug string, id int64) bool {
+	for _, stack := range client.StackItems {
+		if stack.Slug == slug && stack.ID != id && stack.Status != StackStatusDeleted {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultStackURL(slug string) string {
+	return fmt.Sprintf("https://%s.grafana.net", strings.ToLower(slug))
+}
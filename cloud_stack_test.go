@@ -0,0 +1,411 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestNewStack(t *testing.T) {
+	t.Run("should create stack with derived urls and instances", func(t *testing.T) {
+		client := NewClient()
+
+		id, err := client.NewStack(&gapi.CreateStackInput{Name: "CLabs", Slug: "clabs", Region: "eu"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		stack, err := client.StackByID(id)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if stack.URL != "https://clabs.grafana.net" {
+			t.Errorf("got url %q", stack.URL)
+		}
+		if stack.RegionSlug != "eu" || stack.Status != "active" {
+			t.Errorf("got region %q and status %q", stack.RegionSlug, stack.Status)
+		}
+		if stack.HmInstancePromID == 0 || stack.HlInstanceID == 0 || stack.HtInstanceID == 0 {
+			t.Errorf("expected instance ids to be set but got %+v", stack)
+		}
+		if stack.HmInstancePromID == stack.HlInstanceID || stack.HlInstanceID == stack.HtInstanceID {
+			t.Errorf("expected distinct instance ids but got %+v", stack)
+		}
+		if !strings.Contains(stack.HmInstancePromURL, "eu") {
+			t.Errorf("expected prometheus url in the stack region but got %q", stack.HmInstancePromURL)
+		}
+	})
+
+	t.Run("should default to the us region", func(t *testing.T) {
+		client := NewClient()
+
+		id, _ := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"})
+
+		stack, _ := client.StackByID(id)
+		if stack.RegionSlug != "us" {
+			t.Errorf("got region %q want us", stack.RegionSlug)
+		}
+	})
+
+	t.Run("should not create stack in unknown region", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs", Region: "moon"})
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+
+	t.Run("should not create stack with invalid slug", func(t *testing.T) {
+		client := NewClient()
+
+		for _, slug := range []string{"", "1clabs", "c-labs", "CLabs"} {
+			_, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: slug})
+			if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+				t.Errorf("slug %q: expected a bad request error but got %v", slug, err)
+			}
+		}
+	})
+
+	t.Run("should not create stack with duplicate slug", func(t *testing.T) {
+		client := NewClient()
+		client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"})
+
+		_, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs", Region: "eu"})
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error but got %v", err)
+		}
+		if len(client.StackItems) != 1 {
+			t.Errorf("got %d stacks want 1", len(client.StackItems))
+		}
+	})
+}
+
+func TestStacks(t *testing.T) {
+	t.Run("should list and look up stacks", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStacks(3, "clabs")
+
+		stacks, _ := client.Stacks()
+		if len(stacks.Items) != 3 {
+			t.Fatalf("got %d stacks want 3", len(stacks.Items))
+		}
+
+		stack, err := client.StackBySlug(stacks.Items[1].Slug)
+		if err != nil || stack.ID != stacks.Items[1].ID {
+			t.Errorf("got %+v, %v", stack, err)
+		}
+	})
+
+	t.Run("should return not found for unknown stack", func(t *testing.T) {
+		client := NewClient()
+
+		if _, err := client.StackBySlug("clabs"); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+		if _, err := client.StackByID(1); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+	})
+}
+
+func TestGenerateStacks(t *testing.T) {
+	t.Run("should give each stack a unique slug", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStacks(3, "clabs")
+
+		stacks, err := client.GenerateStacks(2, "clabs")
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if stacks[0].Slug != "clabs4" || stacks[1].Slug != "clabs5" {
+			t.Errorf("got slugs %q and %q want clabs4 and clabs5", stacks[0].Slug, stacks[1].Slug)
+		}
+	})
+
+	t.Run("should reject a prefix that can't make a valid slug", func(t *testing.T) {
+		client := NewClient()
+
+		for _, prefix := range []string{"c-labs", "CLabs", "1clabs", strings.Repeat("c", 29)} {
+			_, err := client.GenerateStacks(2, prefix)
+			if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+				t.Errorf("prefix %q: expected a bad request error but got %v", prefix, err)
+			}
+		}
+		if len(client.StackItems) != 0 {
+			t.Errorf("expected no stacks but found %d", len(client.StackItems))
+		}
+	})
+}
+
+func TestUpdateStack(t *testing.T) {
+	t.Run("should rename stack and move its default url", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+
+		err := client.UpdateStack(stack.ID, &gapi.UpdateStackInput{Name: "Celo", Slug: "celo", Description: "celo stack"})
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		got, _ := client.StackByID(stack.ID)
+		if got.Name != "Celo" || got.Slug != "celo" || got.Description != "celo stack" {
+			t.Errorf("got %+v", got)
+		}
+		if got.URL != "https://celo.grafana.net" {
+			t.Errorf("got url %q", got.URL)
+		}
+	})
+
+	t.Run("should not update slug to one already in use", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+		client.GenerateStack("celo")
+
+		err := client.UpdateStack(stack.ID, &gapi.UpdateStackInput{Slug: "celo"})
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error but got %v", err)
+		}
+	})
+}
+
+func TestDeleteStack(t *testing.T) {
+	t.Run("should delete stack if it exists", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateStack("clabs")
+
+		if err := client.DeleteStack("clabs"); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if stacks, _ := client.Stacks(); len(stacks.Items) != 0 {
+			t.Errorf("expected no stacks but found %v", stacks.Items)
+		}
+		if stack, _ := client.StackBySlug("clabs"); stack.Status != StackStatusDeleted {
+			t.Errorf("got status %q want %q", stack.Status, StackStatusDeleted)
+		}
+	})
+
+	t.Run("should return not found for unknown stack", func(t *testing.T) {
+		client := NewClient()
+
+		if err := client.DeleteStack("clabs"); err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+
+	t.Run("should return not found for a stack that is already deleted", func(t *testing.T) {
+		client := NewClient()
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		client.Now = func() time.Time { return now }
+		client.StackDeleteDelay = time.Minute
+		client.GenerateStack("clabs")
+		client.DeleteStack("clabs")
+		now = now.Add(time.Minute)
+
+		if err := client.DeleteStack("clabs"); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+		if stack, _ := client.StackBySlug("clabs"); stack.Status != StackStatusDeleted {
+			t.Errorf("got status %q want %q", stack.Status, StackStatusDeleted)
+		}
+		if stacks, _ := client.Stacks(); len(stacks.Items) != 0 {
+			t.Errorf("expected no stacks but found %v", stacks.Items)
+		}
+	})
+}
+
+func TestStackRealms(t *testing.T) {
+	t.Run("should only accept realms for existing stacks in strict mode", func(t *testing.T) {
+		client := NewClient()
+		client.Strict = true
+		stack, _ := client.GenerateStack("clabs")
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", "clabs")},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+
+		input.Realms = []gapi.CloudAccessPolicyRealm{NewRealm("stack", fmt.Sprintf("%d", stack.ID))}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should check realms against existing stacks without strict mode", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+		client.GenerateStack("celo")
+		client.DeleteStack("celo")
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", fmt.Sprintf("%d", stack.ID+1))},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error for a deleted stack but got %v", err)
+		}
+
+		input.Realms = []gapi.CloudAccessPolicyRealm{NewRealm("stack", fmt.Sprintf("%d", stack.ID))}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should accept any stack realm when there are no stacks", func(t *testing.T) {
+		client := NewClient()
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", "clabs")},
+		}
+		if _, err := client.CreateCloudAccessPolicy("us", input); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should generate realms for existing stacks", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+
+		realm := client.GenerateRealm()
+		if realm.Type != "stack" || realm.Identifier != fmt.Sprintf("%d", stack.ID) {
+			t.Errorf("got realm %+v", realm)
+		}
+	})
+}
+
+func TestStackLifecycle(t *testing.T) {
+	t.Run("should keep new stack pending until the create delay has passed", func(t *testing.T) {
+		client := NewClient()
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		client.Now = func() time.Time { return now }
+		client.StackCreateDelay = time.Minute
+
+		id, _ := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"})
+
+		stack, _ := client.StackByID(id)
+		if stack.Status != StackStatusPending || stack.HmInstancePromStatus != StackStatusPending {
+			t.Errorf("got status %q and prometheus status %q want pending", stack.Status, stack.HmInstancePromStatus)
+		}
+		if err := client.UpdateStack(id, &gapi.UpdateStackInput{Name: "celo"}); err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error updating a pending stack but got %v", err)
+		}
+
+		now = now.Add(time.Minute)
+		stack, _ = client.StackByID(id)
+		if stack.Status != StackStatusActive {
+			t.Errorf("got status %q want active", stack.Status)
+		}
+		if err := client.UpdateStack(id, &gapi.UpdateStackInput{Name: "celo"}); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should keep deleted stack deleting until the delete delay has passed", func(t *testing.T) {
+		client := NewClient()
+		now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		client.Now = func() time.Time { return now }
+		client.StackDeleteDelay = time.Minute
+		client.GenerateStack("clabs")
+
+		client.DeleteStack("clabs")
+
+		stack, _ := client.StackBySlug("clabs")
+		if stack.Status != StackStatusDeleting {
+			t.Errorf("got status %q want deleting", stack.Status)
+		}
+		if err := client.DeleteStack("clabs"); err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error deleting twice but got %v", err)
+		}
+		if _, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"}); err == nil {
+			t.Errorf("expected slug to stay in use while deleting")
+		}
+
+		now = now.Add(time.Minute)
+		stack, _ = client.StackBySlug("clabs")
+		if stack.Status != StackStatusDeleted {
+			t.Errorf("got status %q want deleted", stack.Status)
+		}
+		if _, err := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"}); err != nil {
+			t.Errorf("expected slug to be reusable once deleted but got %v", err)
+		}
+	})
+
+	t.Run("should set stack status directly", func(t *testing.T) {
+		client := NewClient()
+		client.StackCreateDelay = time.Hour
+		id, _ := client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs"})
+
+		if err := client.SetStackStatus(id, StackStatusActive); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		stack, _ := client.StackByID(id)
+		if stack.Status != StackStatusActive {
+			t.Errorf("got status %q want active", stack.Status)
+		}
+		if err := client.SetStackStatus(id, "paused"); err == nil {
+			t.Errorf("expected error for unknown status but got none")
+		}
+	})
+}
+
+func TestInstanceClient(t *testing.T) {
+	t.Run("should return each stack's own instance", func(t *testing.T) {
+		client := NewClient()
+		clabs, _ := client.GenerateStack("clabs")
+		celo, _ := client.GenerateStack("celo")
+
+		instance, err := client.InstanceClient(clabs.URL + "/")
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		instance.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "deploy"})
+
+		again, _ := client.InstanceClient(clabs.URL)
+		if again != instance || len(again.ServiceAccountsDTO) != 1 {
+			t.Errorf("expected the same instance for the same stack")
+		}
+		other, _ := client.InstanceClient(celo.URL)
+		if len(other.ServiceAccountsDTO) != 0 {
+			t.Errorf("expected stacks not to share service accounts but found %v", other.ServiceAccountsDTO)
+		}
+	})
+
+	t.Run("should find stack whose url has a trailing slash", func(t *testing.T) {
+		client := NewClient()
+		client.NewStack(&gapi.CreateStackInput{Name: "clabs", Slug: "clabs", URL: "https://x.grafana.net/"})
+
+		if _, err := client.InstanceClient("https://x.grafana.net"); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if _, err := client.InstanceClient("https://x.grafana.net/"); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should fail for unknown and deleted stacks", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+		client.DeleteStack("clabs")
+
+		if _, err := client.InstanceClient(stack.URL); err == nil || !strings.HasPrefix(err.Error(), "status: 404") {
+			t.Errorf("expected a not found error but got %v", err)
+		}
+		if _, err := client.InstanceClient("https://celo.grafana.net"); err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// Matcher types supported in label policy selectors, following prometheus
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+// LabelMatcher is a single prometheus label matcher such as env="dev" or team=~"infra.*"
+type LabelMatcher struct {
+	Name  string
+	Type  string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// Matches reports whether the label value satisfies the matcher. A missing label matches as the empty string.
+func (m LabelMatcher) Matches(value string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+// MatchLabels reports whether the labels satisfy every matcher
+func MatchLabels(matchers []LabelMatcher, labels map[string]string) bool {
+	for _, matcher := range matchers {
+		if !matcher.Matches(labels[matcher.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabelSelector parses a prometheus label selector such as {env="dev", team=~"infra.*"} into its matchers
+func ParseLabelSelector(selector string) ([]LabelMatcher, error) {
+	s := strings.TrimSpace(selector)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid selector %q: must be enclosed in braces", selector)
+	}
+	s = s[1 : len(s)-1]
+
+	matchers := make([]LabelMatcher, 0)
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			break
+		}
+
+		var matcher LabelMatcher
+		var err error
+		matcher.Name, s = scanLabelName(s)
+		if matcher.Name == "" {
+			return nil, fmt.Errorf("invalid selector %q: expected label name", selector)
+		}
+
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		for _, op := range []string{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+			if strings.HasPrefix(s, op) {
+				matcher.Type = op
+				s = s[len(op):]
+				break
+			}
+		}
+		if matcher.Type == "" {
+			return nil, fmt.Errorf("invalid selector %q: expected matcher operator after %q", selector, matcher.Name)
+		}
+
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		matcher.Value, s, err = scanLabelValue(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+		}
+
+		if matcher.Type == MatchRegexp || matcher.Type == MatchNotRegexp {
+			matcher.re, err = regexp.Compile("^(?:" + matcher.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+			}
+		}
+		matchers = append(matchers, matcher)
+
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			break
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("invalid selector %q: expected comma after %q", selector, matcher.Name)
+		}
+		s = s[1:]
+	}
+
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("invalid selector %q: at least one matcher is required", selector)
+	}
+	return matchers, nil
+}
+
+func scanLabelName(s string) (string, string) {
+	end := 0
+	for end < len(s) {
+		c := s[end]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (end > 0 && c >= '0' && c <= '9') {
+			end++
+			continue
+		}
+		break
+	}
+	return s[:end], s[end:]
+}
+
+func scanLabelValue(s string) (string, string, error) {
+	if s == "" || (s[0] != '"' && s[0] != '`') {
+		return "", s, fmt.Errorf("expected quoted label value")
+	}
+
+	quote := s[0]
+	for end := 1; end < len(s); end++ {
+		if s[end] == '\\' && quote == '"' {
+			end++
+			continue
+		}
+		if s[end] == quote {
+			value, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return "", s, fmt.Errorf("invalid label value %s", s[:end+1])
+			}
+			return value, s[end+1:], nil
+		}
+	}
+	return "", s, fmt.Errorf("unterminated label value")
+}
+
+// validateRealms checks the realm types and parses every label policy selector, returning grafana's
+// bad request error for the first problem found. Once the client has stacks, stack realms must refer to one that
+// hasn't been deleted, and once CloudOrgs is set, org realms must refer to a known org. Strict mode checks both always.
+func (client *MockClient) validateRealms(realms []gapi.CloudAccessPolicyRealm) error {
+	for _, realm := range realms {
+		if realm.Type != "org" && realm.Type != "stack" {
+			return apiError(http.StatusBadRequest, fmt.Sprintf("invalid realm type %q", realm.Type))
+		}
+		if realm.Type == "stack" && (client.Strict || len(client.StackItems) > 0) {
+			client.refreshStacks()
+			id, err := strconv.ParseInt(realm.Identifier, 10, 64)
+			if stack := client.stackByID(id); err != nil || stack == nil || stack.Status == StackStatusDeleted {
+				return apiError(http.StatusBadRequest, fmt.Sprintf("stack %q not found", realm.Identifier))
+			}
+		}
+		if realm.Type == "org" && (client.Strict || client.CloudOrgs != nil) {
+			if _, err := strconv.ParseInt(realm.Identifier, 10, 64); err != nil || client.cloudOrg(realm.Identifier) == nil {
+				return apiError(http.StatusBadRequest, fmt.Sprintf("org %q not found", realm.Identifier))
+			}
+		}
+		for _, labelPolicy := range realm.LabelPolicies {
+			if _, err := ParseLabelSelector(labelPolicy.Selector); err != nil {
+				return apiError(http.StatusBadRequest, err.Error())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package mockgrafana
+
+import (
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	t.Run("should parse every matcher type", func(t *testing.T) {
+		matchers, err := ParseLabelSelector(`{env="dev", team=~"infra.*", region!="eu",tier!~` + "`db|cache`" + `}`)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		want := []LabelMatcher{
+			{Name: "env", Type: MatchEqual, Value: "dev"},
+			{Name: "team", Type: MatchRegexp, Value: "infra.*"},
+			{Name: "region", Type: MatchNotEqual, Value: "eu"},
+			{Name: "tier", Type: MatchNotRegexp, Value: "db|cache"},
+		}
+		if len(matchers) != len(want) {
+			t.Fatalf("got %d matchers want %d", len(matchers), len(want))
+		}
+		for i := range want {
+			got := matchers[i]
+			if got.Name != want[i].Name || got.Type != want[i].Type || got.Value != want[i].Value {
+				t.Errorf("got %+v want %+v", got, want[i])
+			}
+		}
+	})
+
+	t.Run("should reject malformed selectors", func(t *testing.T) {
+		selectors := []string{
+			``,
+			`env="dev"`,
+			`{}`,
+			`{env}`,
+			`{env="dev" team="infra"}`,
+			`{env=dev}`,
+			`{env="dev}`,
+			`{1env="dev"}`,
+			`{env=~"("}`,
+		}
+		for _, selector := range selectors {
+			if _, err := ParseLabelSelector(selector); err == nil {
+				t.Errorf("expected an error for %q, got none", selector)
+			}
+		}
+	})
+}
+
+func TestMatchLabels(t *testing.T) {
+	matchers, _ := ParseLabelSelector(`{env="dev", team=~"infra.*"}`)
+
+	cases := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"env": "dev", "team": "infra-core"}, true},
+		{map[string]string{"env": "prod", "team": "infra-core"}, false},
+		{map[string]string{"env": "dev", "team": "core-infra"}, false},
+		{map[string]string{"env": "dev"}, false},
+	}
+	for _, c := range cases {
+		if got := MatchLabels(matchers, c.labels); got != c.want {
+			t.Errorf("labels %v: got %v want %v", c.labels, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package mockgrafana
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// LogStream is a loki stream as sent in a push request
+type LogStream struct {
+	Labels  map[string]string
+	Entries []LogEntry
+}
+
+// LogEntry is a single line of a stream
+type LogEntry struct {
+	Timestamp time.Time
+	Line      string
+}
+
+// LokiPush stands in for a stack's loki push endpoint. The username is the stack's loki instance id and the password
+// a cloud access policy token. The token needs the logs:write scope and a realm covering the stack, and every stream
+// must match one of the realm's label policies when it has any. Accepted streams are kept for ReceivedStreams.
+// Handler serves it at /loki/api/v1/push.
+func (client *MockClient) LokiPush(username, password string, streams []LogStream) error {
+	stack, err := client.stackByInstanceID(username, func(stack *gapi.Stack) int { return stack.HlInstanceID })
+	if err != nil {
+		return err
+	}
+	labelPolicies, err := client.authorizeInstance(stack, password, "logs:write")
+	if err != nil {
+		return err
+	}
+	for _, stream := range streams {
+		if len(stream.Labels) == 0 {
+			return apiError(http.StatusBadRequest, "stream has no labels")
+		}
+		if !matchLabelPolicies(labelPolicies, stream.Labels) {
+			return apiError(http.StatusForbidden, fmt.Sprintf("stream %v is not allowed by the access policy's label policies", stream.Labels))
+		}
+	}
+
+	if client.receivedStreams == nil {
+		client.receivedStreams = make(map[int64][]LogStream)
+	}
+	client.receivedStreams[stack.ID] = append(client.receivedStreams[stack.ID], streams...)
+	return nil
+}
+
+// serveLokiPush decodes a loki push request and passes its streams to LokiPush. Like loki, it accepts JSON bodies,
+// optionally gzipped, and otherwise expects the snappy compressed protobuf that promtail and grafana agents send.
+func (client *MockClient) serveLokiPush(w http.ResponseWriter, r *http.Request, username, password string) error {
+	if err := requireMethod(r, http.MethodPost); err != nil {
+		return err
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return apiError(http.StatusBadRequest, fmt.Sprintf("invalid gzip body: %s", err))
+		}
+		body = gzipReader
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return apiError(http.StatusBadRequest, err.Error())
+	}
+
+	var streams []LogStream
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		streams, err = decodeLokiJSON(b)
+	} else if b, err = snappyDecode(b); err == nil {
+		streams, err = decodeLokiProto(b)
+	}
+	if err != nil {
+		return apiError(http.StatusBadRequest, fmt.Sprintf("invalid push request: %s", err))
+	}
+
+	if err := client.LokiPush(username, password, streams); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// decodeLokiJSON decodes the streams of a JSON push request, where each value is a pair of a unix nanosecond
+// timestamp and a line. Any structured metadata after the line is ignored.
+func decodeLokiJSON(b []byte) ([]LogStream, error) {
+	var request struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(b, &request); err != nil {
+		return nil, err
+	}
+
+	var streams []LogStream
+	for _, s := range request.Streams {
+		stream := LogStream{Labels: s.Stream}
+		for _, value := range s.Values {
+			var timestamp, line string
+			if len(value) < 2 || json.Unmarshal(value[0], &timestamp) != nil || json.Unmarshal(value[1], &line) != nil {
+				return nil, fmt.Errorf("values must be pairs of a timestamp and a line")
+			}
+			nanos, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp %q", timestamp)
+			}
+			stream.Entries = append(stream.Entries, LogEntry{Timestamp: time.Unix(0, nanos), Line: line})
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// decodeLokiProto decodes the streams of a protobuf PushRequest, whose labels are a selector such as
+// {job="api", env="dev"}
+func decodeLokiProto(b []byte) ([]LogStream, error) {
+	var streams []LogStream
+	err := decodeMessage(b, func(field int, wireType int, value []byte, number uint64) error {
+		if field != 1 || wireType != protoBytes {
+			return nil
+		}
+		var stream LogStream
+		err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+			switch {
+			case field == 1 && wireType == protoBytes:
+				matchers, err := ParseLabelSelector(string(value))
+				if err != nil {
+					return err
+				}
+				stream.Labels = make(map[string]string)
+				for _, matcher := range matchers {
+					if matcher.Type != MatchEqual {
+						return fmt.Errorf("invalid stream labels %q", value)
+					}
+					stream.Labels[matcher.Name] = matcher.Value
+				}
+			case field == 2 && wireType == protoBytes:
+				var entry LogEntry
+				err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+					switch {
+					case field == 1 && wireType == protoBytes:
+						var seconds, nanos int64
+						err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+							switch {
+							case field == 1 && wireType == protoVarint:
+								seconds = int64(number)
+							case field == 2 && wireType == protoVarint:
+								nanos = int64(number)
+							}
+							return nil
+						})
+						entry.Timestamp = time.Unix(seconds, nanos)
+						return err
+					case field == 2 && wireType == protoBytes:
+						entry.Line = string(value)
+					}
+					return nil
+				})
+				stream.Entries = append(stream.Entries, entry)
+				return err
+			}
+			return nil
+		})
+		streams = append(streams, stream)
+		return err
+	})
+	return streams, err
+}
+
+// ReceivedStreams returns the streams pushed to the stack with the given slug
+func (client *MockClient) ReceivedStreams(stackSlug string) []LogStream {
+	stack := client.stackBySlug(stackSlug)
+	if stack == nil {
+		return nil
+	}
+	return client.receivedStreams[stack.ID]
+}
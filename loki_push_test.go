@@ -0,0 +1,159 @@
+package mockgrafana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLokiPush(t *testing.T) {
+	streams := []LogStream{{
+		Labels:  map[string]string{"job": "api", "env": "dev"},
+		Entries: []LogEntry{{Timestamp: time.Unix(1, 0), Line: "started"}},
+	}}
+
+	t.Run("should accept streams with a logs:write token for the stack", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"})
+
+		if err := client.LokiPush(fmt.Sprintf("%d", stack.HlInstanceID), token, streams); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if got := client.ReceivedStreams("clabs"); len(got) != 1 || got[0].Entries[0].Line != "started" {
+			t.Errorf("got received streams %v", got)
+		}
+	})
+
+	t.Run("should reject the prometheus instance id", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"})
+
+		err := client.LokiPush(fmt.Sprintf("%d", stack.HmInstancePromID), token, streams)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 401") {
+			t.Errorf("expected an unauthorized error but got %v", err)
+		}
+	})
+
+	t.Run("should reject token without logs:write", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+
+		err := client.LokiPush(fmt.Sprintf("%d", stack.HlInstanceID), token, streams)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+	})
+
+	t.Run("should enforce label policies", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"}, `{env="dev"}`)
+		username := fmt.Sprintf("%d", stack.HlInstanceID)
+
+		prod := []LogStream{{Labels: map[string]string{"job": "api", "env": "prod"}}}
+		if err := client.LokiPush(username, token, prod); err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+		if err := client.LokiPush(username, token, streams); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		if got := client.ReceivedStreams("clabs"); len(got) != 1 {
+			t.Errorf("expected only the allowed stream to be stored but got %v", got)
+		}
+	})
+}
+
+func TestServeLokiPush(t *testing.T) {
+	jsonBody := []byte(`{"streams": [{"stream": {"job": "api", "env": "dev"}, "values": [["1000000000", "started", {"trace_id": "1"}]]}]}`)
+
+	push := func(t *testing.T, url, username, password string, header http.Header, body []byte) int {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPost, url+"/loki/api/v1/push", bytes.NewReader(body))
+		request.Header = header
+		request.SetBasicAuth(username, password)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	t.Run("should accept json and gzipped json pushes", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"})
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+		username := fmt.Sprintf("%d", stack.HlInstanceID)
+
+		header := http.Header{"Content-Type": {"application/json"}}
+		if got := push(t, server.URL, username, token, header, jsonBody); got != http.StatusNoContent {
+			t.Fatalf("got status %d want %d", got, http.StatusNoContent)
+		}
+		var gzipped bytes.Buffer
+		writer := gzip.NewWriter(&gzipped)
+		writer.Write(jsonBody)
+		writer.Close()
+		header.Set("Content-Encoding", "gzip")
+		if got := push(t, server.URL, username, token, header, gzipped.Bytes()); got != http.StatusNoContent {
+			t.Fatalf("got status %d want %d for gzip", got, http.StatusNoContent)
+		}
+
+		got := client.ReceivedStreams("clabs")
+		if len(got) != 2 || got[1].Labels["env"] != "dev" || got[1].Entries[0].Line != "started" || !got[1].Entries[0].Timestamp.Equal(time.Unix(1, 0)) {
+			t.Errorf("got received streams %v", got)
+		}
+	})
+
+	t.Run("should accept protobuf pushes", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"})
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+
+		timestamp := binary.AppendUvarint(nil, 1<<3|protoVarint)
+		timestamp = binary.AppendUvarint(timestamp, 1)
+		entry := appendProtoField(nil, 1, timestamp)
+		entry = appendProtoField(entry, 2, []byte("started"))
+		stream := appendProtoField(nil, 1, []byte(`{job="api", env="dev"}`))
+		stream = appendProtoField(stream, 2, entry)
+		body := snappyLiteral(appendProtoField(nil, 1, stream))
+
+		header := http.Header{"Content-Type": {"application/x-protobuf"}}
+		if got := push(t, server.URL, fmt.Sprintf("%d", stack.HlInstanceID), token, header, body); got != http.StatusNoContent {
+			t.Fatalf("got status %d want %d", got, http.StatusNoContent)
+		}
+
+		got := client.ReceivedStreams("clabs")
+		if len(got) != 1 || got[0].Labels["job"] != "api" || got[0].Entries[0].Line != "started" || !got[0].Entries[0].Timestamp.Equal(time.Unix(1, 0)) {
+			t.Errorf("got received streams %v", got)
+		}
+	})
+
+	t.Run("should serve the errors of LokiPush", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"logs:write"}, `{env="prod"}`)
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+		username := fmt.Sprintf("%d", stack.HlInstanceID)
+		header := http.Header{"Content-Type": {"application/json"}}
+
+		if got := push(t, server.URL, username, token, header, jsonBody); got != http.StatusForbidden {
+			t.Errorf("got status %d want %d for a stream outside the label policies", got, http.StatusForbidden)
+		}
+		if got := push(t, server.URL, username, token, header, []byte(`{"streams": [{"stream": {"env": "prod"}, "values": [["now", "started"]]}]}`)); got != http.StatusBadRequest {
+			t.Errorf("got status %d want %d for an invalid timestamp", got, http.StatusBadRequest)
+		}
+		if got := client.ReceivedStreams("clabs"); len(got) != 0 {
+			t.Errorf("expected no streams to be stored but got %v", got)
+		}
+	})
+}
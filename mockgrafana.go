@@ -1,9 +1,14 @@
 package mockgrafana
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/grafana/grafana-api-golang-client"
 	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,20 +20,87 @@ type MockClient struct {
 	CloudAPIKeys                []*gapi.CloudAPIKey
 	CloudAccessPolicyItems      []*gapi.CloudAccessPolicy
 	CloudAccessPolicyTokenItems []*gapi.CloudAccessPolicyToken
+	APIKeys                     []APIKey
+	StackItems                  []*gapi.Stack
+	CloudPluginInstallations    []*gapi.CloudPluginInstallation
+
+	// CloudOrgs are the grafana cloud orgs the mock knows about. It defaults to a single org with
+	// the client's OrgID when nil.
+	CloudOrgs []gapi.CloudOrg
+
+	// CloudRegions are the regions stacks can be created in. It defaults to DefaultCloudRegions
+	// when nil.
+	CloudRegions []gapi.CloudRegion
+
+	// SyntheticSeries are served by QueryMetrics for every stack, alongside the series
+	// remote-written to it.
+	SyntheticSeries []Series
+
+	// PluginCatalogue is the set of plugins that can be installed on stacks. It defaults to
+	// DefaultPluginCatalogue when nil.
+	PluginCatalogue []CloudPlugin
+
+	// Now returns the current time as seen by the mock. It defaults to time.Now and
+	// can be replaced to control token expiry deterministically.
+	Now func() time.Time
+
+	// OrgID is the grafana org the mock acts as. It is embedded in the tokens the mock issues
+	// and defaults to 1.
+	OrgID int64
+
+	// Strict enables validation that mirrors grafana cloud more closely, such as rejecting
+	// access policy scopes outside CloudAccessPolicyScopes and realms for stacks or orgs that
+	// don't exist. Without it, stack realms are still checked once the client has stacks and
+	// org realms once CloudOrgs is set.
+	Strict bool
+
+	// Quotas limits the access policies, access policy tokens and service accounts that can be
+	// created. Stack instances start with the cloud client's quotas.
+	Quotas Quotas
+
+	// StackCreateDelay and StackDeleteDelay are how long, by the mock's clock, new stacks stay pending
+	// and deleted stacks stay deleting. Both default to 0 so stack changes apply immediately.
+	StackCreateDelay time.Duration
+	StackDeleteDelay time.Duration
+
+	// secrets are only kept hashed, and only returned from the call that created them
+	cloudAPIKeyHashes       map[*gapi.CloudAPIKey]string
+	accessPolicyTokenHashes map[*gapi.CloudAccessPolicyToken]string
+
+	accessPolicyTokenLastUsed map[*gapi.CloudAccessPolicyToken]time.Time
+
+	stackTransitions map[*gapi.Stack]stackTransition
+	// receivedSeries holds the series remote-written to each stack, keyed by stack id
+	receivedSeries map[int64][]Series
+	// receivedStreams holds the log streams pushed to each stack, keyed by stack id
+	receivedStreams map[int64][]LogStream
+	// stackInstances holds the grafana instance of each stack, keyed by stack id
+	stackInstances map[int64]*MockClient
 }
 
-// Token  is a simulation of a grafana api token
+// Token  is a simulation of a grafana api token. Like grafana, only a hash of the key is stored.
 type Token struct {
 	ID               int64      `json:"id"`
 	Name             string     `json:"name"`
 	Created          time.Time  `json:"created,omitempty"`
-	Key              string     `json:"key"`
+	HashedKey        string     `json:"-"`
 	Expiration       *time.Time `json:"expiration,omitempty"`
 	ServiceAccountID int64      `json:"-"`
 	SecondsToLive    int64      `json:"secondsToLive,omitempty"`
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
 }
 
-func (ClientWrapper *MockClient) Initialize(key, org string) error {
+// Initialize simulates authenticating the client with an api key. Like grafana, known tokens that
+// have expired or belong to a disabled service account are rejected, and successful use is recorded.
+func (client *MockClient) Initialize(key, org string) error {
+	identity := client.findToken(key)
+	if identity == nil {
+		return nil
+	}
+	if err := client.checkToken(identity); err != nil {
+		return err
+	}
+	client.recordTokenUse(identity)
 	return nil
 }
 
@@ -37,6 +109,87 @@ func NewClient() *MockClient {
 	return &MockClient{}
 }
 
+func (client *MockClient) now() time.Time {
+	if client.Now == nil {
+		return time.Now()
+	}
+	return client.Now()
+}
+
+func (client *MockClient) orgID() int64 {
+	if client.OrgID == 0 {
+		return 1
+	}
+	return client.OrgID
+}
+
+// cloudNamePattern is the slug format grafana cloud requires for access policy and token names
+var cloudNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func validateCloudName(name string) error {
+	if !cloudNamePattern.MatchString(name) {
+		return apiError(http.StatusBadRequest, fmt.Sprintf("invalid name %q: must contain only lowercase letters, digits and dashes", name))
+	}
+	return nil
+}
+
+// cloudNameInvalidChars matches the runs of characters cloudNamePattern doesn't allow
+var cloudNameInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugifyCloudName lowercases name and replaces anything else cloudNamePattern rejects with dashes, so
+// "Test Policy" becomes "test-policy"
+func slugifyCloudName(name string) string {
+	return strings.Trim(cloudNameInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// apiError returns an error shaped like the ones gapi returns for a non-2xx grafana response
+func apiError(status int, message string) error {
+	body, _ := json.Marshal(map[string]string{
+		"code":    strings.ReplaceAll(http.StatusText(status), " ", ""),
+		"message": message,
+	})
+	return &statusError{status: status, body: body}
+}
+
+// statusError keeps the status and body of an apiError so Handler can serve them as the response
+type statusError struct {
+	status int
+	body   []byte
+}
+
+func (err *statusError) Error() string {
+	return fmt.Sprintf("status: %d, body: %s", err.status, err.body)
+}
+
+// nextCloudID returns an id above every given numeric id, so ids aren't reused after a delete
+func nextCloudID(ids []string) string {
+	var max int64
+	for _, id := range ids {
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil && n > max {
+			max = n
+		}
+	}
+	return strconv.FormatInt(max+1, 10)
+}
+
+// nextCloudAccessPolicyID returns an id above every current access policy id
+func (c *MockClient) nextCloudAccessPolicyID() string {
+	ids := make([]string, 0, len(c.CloudAccessPolicyItems))
+	for _, policy := range c.CloudAccessPolicyItems {
+		ids = append(ids, policy.ID)
+	}
+	return nextCloudID(ids)
+}
+
+// nextCloudAccessPolicyTokenID returns an id above every current access policy token id
+func (c *MockClient) nextCloudAccessPolicyTokenID() string {
+	ids := make([]string, 0, len(c.CloudAccessPolicyTokenItems))
+	for _, token := range c.CloudAccessPolicyTokenItems {
+		ids = append(ids, token.ID)
+	}
+	return nextCloudID(ids)
+}
+
 func (c *MockClient) CloudAccessPolicies(region string) (gapi.CloudAccessPolicyItems, error) {
 	if region == "" {
 		return gapi.CloudAccessPolicyItems{}, fmt.Errorf("region required")
@@ -82,23 +235,74 @@ func (c *MockClient) CreateCloudAccessPolicy(region string, input gapi.CreateClo
 		return gapi.CloudAccessPolicy{}, fmt.Errorf("region required")
 	}
 
-	for _, realm := range input.Realms {
-		if realm.Type != "org" && realm.Type != "stack" {
-			return gapi.CloudAccessPolicy{}, fmt.Errorf("invalid realm type")
+	if err := c.validateScopes(input.Scopes); err != nil {
+		return gapi.CloudAccessPolicy{}, err
+	}
+	if err := c.validateRealms(input.Realms); err != nil {
+		return gapi.CloudAccessPolicy{}, err
+	}
+	if err := validateCloudName(input.Name); err != nil {
+		return gapi.CloudAccessPolicy{}, err
+	}
+	for _, policy := range c.CloudAccessPolicyItems {
+		if policy.Name == input.Name {
+			return gapi.CloudAccessPolicy{}, apiError(http.StatusConflict, fmt.Sprintf("access policy %q already exists", input.Name))
 		}
 	}
+	if err := checkQuota(c.Quotas.AccessPoliciesPerOrg, c.QuotaUsage().AccessPolicies); err != nil {
+		return gapi.CloudAccessPolicy{}, err
+	}
 	policy := gapi.CloudAccessPolicy{}
 	policy.Name = input.Name
 	policy.DisplayName = input.DisplayName
 	policy.Scopes = input.Scopes
 	policy.Realms = input.Realms
-	policy.ID = fmt.Sprintf("%d", len(c.CloudAccessPolicyItems)+1)
-	policy.CreatedAt = time.Now()
+	policy.ID = c.nextCloudAccessPolicyID()
+	policy.OrgID = fmt.Sprintf("%d", c.orgID())
+	policy.CreatedAt = c.now()
 
 	c.CloudAccessPolicyItems = append(c.CloudAccessPolicyItems, &policy)
 	return policy, nil
 }
 
+// UpdateCloudAccessPolicy will apply the display name, scopes and realms set on the input to the fake Cloud Access Policy
+// that matches the given ID, validating them the same way CreateCloudAccessPolicy does
+func (c *MockClient) UpdateCloudAccessPolicy(region, id string, input gapi.UpdateCloudAccessPolicyInput) (gapi.CloudAccessPolicy, error) {
+	if region == "" {
+		return gapi.CloudAccessPolicy{}, fmt.Errorf("region required")
+	}
+
+	for _, policy := range c.CloudAccessPolicyItems {
+		if policy.ID != id {
+			continue
+		}
+
+		if input.Scopes != nil {
+			if err := c.validateScopes(input.Scopes); err != nil {
+				return gapi.CloudAccessPolicy{}, err
+			}
+		}
+		if input.Realms != nil {
+			if err := c.validateRealms(input.Realms); err != nil {
+				return gapi.CloudAccessPolicy{}, err
+			}
+		}
+
+		if input.DisplayName != "" {
+			policy.DisplayName = input.DisplayName
+		}
+		if input.Scopes != nil {
+			policy.Scopes = input.Scopes
+		}
+		if input.Realms != nil {
+			policy.Realms = input.Realms
+		}
+		policy.UpdatedAt = c.now()
+		return *policy, nil
+	}
+	return gapi.CloudAccessPolicy{}, fmt.Errorf("policy not found")
+}
+
 func (c *MockClient) DeleteCloudAccessPolicy(region, id string) error {
 	if region == "" {
 		return fmt.Errorf("region required")
@@ -129,63 +333,103 @@ func (c *MockClient) DeleteCloudAccessPolicy(region, id string) error {
 	return fmt.Errorf("policy not found")
 }
 
+// GenerateCloudAccessPolicies generates x number of policies (x specified by count). When a prefix is given each
+// policy is named after it with a numeric suffix that keeps the names unique.
 func (client *MockClient) GenerateCloudAccessPolicies(count int, prefix string) []*gapi.CloudAccessPolicy {
 	var policies []*gapi.CloudAccessPolicy
 
 	for i := 0; i < count; i++ {
-		policy := client.GenerateCloudAccessPolicy(prefix)
+		var name string
+		if prefix := slugifyCloudName(prefix); prefix != "" {
+			name = uniqueName(prefix, func(name string) bool {
+				for _, policy := range client.CloudAccessPolicyItems {
+					if policy.Name == name {
+						return true
+					}
+				}
+				return false
+			})
+		}
+		policy := client.GenerateCloudAccessPolicy(name)
+		if policy == nil {
+			break
+		}
 		policies = append(policies, policy)
 	}
 	return policies
 }
 
+// GenerateCloudAccessPolicy creates a policy with a random scope and realm through CreateCloudAccessPolicy, using a
+// random name if none is given. The name is turned into a valid slug first, and nil is returned when the policy
+// still can't be created, such as for a name that is already taken or once the quota is reached.
 func (client *MockClient) GenerateCloudAccessPolicy(name string) *gapi.CloudAccessPolicy {
+	name = slugifyCloudName(name)
 	if name == "" {
-		name = StringGenerator(len(client.CloudAccessPolicyItems) + 1)
+		name = strings.ToLower(StringGenerator(len(client.CloudAccessPolicyItems) + 1))
 	}
-	policy := gapi.CloudAccessPolicy{}
-	policy.Name = name
-	policy.DisplayName = name
-	policy.Scopes = []string{ScopeGenerator()}
-	policy.Realms = []gapi.CloudAccessPolicyRealm{RealmGenerator()}
-	policy.ID = fmt.Sprintf("%d", len(client.CloudAccessPolicyItems)+1)
-	policy.CreatedAt = time.Now()
-
-	client.CloudAccessPolicyItems = append(client.CloudAccessPolicyItems, &policy)
-	return &policy
+	_, err := client.CreateCloudAccessPolicy("us", gapi.CreateCloudAccessPolicyInput{
+		Name:        name,
+		DisplayName: name,
+		Scopes:      []string{ScopeGenerator()},
+		Realms:      []gapi.CloudAccessPolicyRealm{client.GenerateRealm()},
+	})
+	if err != nil {
+		return nil
+	}
+	return client.CloudAccessPolicyItems[len(client.CloudAccessPolicyItems)-1]
 }
 
+// GenerateCloudAccessPolicyTokens generates x number of tokens (x specified by count) for the policy. When a prefix is
+// given each token is named after it with a numeric suffix that keeps the names unique within the policy.
 func (client *MockClient) GenerateCloudAccessPolicyTokens(count int, prefix, accessPolicyID string) []*gapi.CloudAccessPolicyToken {
 	var tokens []*gapi.CloudAccessPolicyToken
 	for i := 0; i < count; i++ {
-		token := client.GenerateCloudAccessPolicyToken(prefix, accessPolicyID)
+		name := slugifyCloudName(prefix)
+		if name == "" {
+			name = "token"
+		}
+		name = uniqueName(name, func(name string) bool {
+			for _, token := range client.CloudAccessPolicyTokenItems {
+				if token.AccessPolicyID == accessPolicyID && token.Name == name {
+					return true
+				}
+			}
+			return false
+		})
+		token := client.GenerateCloudAccessPolicyToken(name, accessPolicyID)
+		if token == nil {
+			break
+		}
 		tokens = append(tokens, token)
 	}
 	return tokens
 }
 
+// GenerateCloudAccessPolicyToken creates a token for the policy through CreateCloudAccessPolicyToken, so it gets a
+// glc_ secret that authenticates like any other. The secret is only set on the returned token. The name is turned into
+// a valid slug first, and nil is returned when the token still can't be created.
 func (client *MockClient) GenerateCloudAccessPolicyToken(name, policyID string) *gapi.CloudAccessPolicyToken {
-    token := gapi.CloudAccessPolicyToken{}
-	token.ID = fmt.Sprintf("%v", len(client.CloudAccessPolicyTokenItems)+1)
-	token.AccessPolicyID = policyID
-	token.Name = name
-	token.DisplayName = name
-	token.CreatedAt = time.Now()
-
-    client.CloudAccessPolicyTokenItems = append(client.CloudAccessPolicyTokenItems, &token)
-
+	name = slugifyCloudName(name)
+	token, err := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+		AccessPolicyID: policyID,
+		Name:           name,
+		DisplayName:    name,
+	})
+	if err != nil {
+		return nil
+	}
 	return &token
 }
 
+// ScopeGenerator returns a random scope from CloudAccessPolicyScopes
 func ScopeGenerator() string {
 	rand.Seed(time.Now().Unix())
-	resources := []string{"metrics", "logs", "traces", "alerts", "rules"}
-	permissions := []string{"read", "write"}
-
-	scope := fmt.Sprintf("%v:%v", resources[rand.Intn(len(resources))], permissions[rand.Intn(len(permissions))])
-	return scope
+	return CloudAccessPolicyScopes[rand.Intn(len(CloudAccessPolicyScopes))]
 }
 
+// RealmGenerator returns an org or stack realm with a random identifier.
+//
+// Deprecated: the identifier doesn't refer to a real org or stack, use MockClient.GenerateRealm instead.
 func RealmGenerator() gapi.CloudAccessPolicyRealm {
 	rand.Seed(time.Now().Unix())
 	realmTypes := []string{"org", "stack"}
@@ -201,27 +445,83 @@ func (c *MockClient) CreateCloudAccessPolicyToken(region string, input gapi.Crea
 		return gapi.CloudAccessPolicyToken{}, fmt.Errorf("region required")
 	}
 
-	var accessPolicyFound bool
-	for _, accessPolicy := range c.CloudAccessPolicyItems {
-		if accessPolicy.ID == input.AccessPolicyID {
-			accessPolicyFound = true
+	var accessPolicy *gapi.CloudAccessPolicy
+	for _, policy := range c.CloudAccessPolicyItems {
+		if policy.ID == input.AccessPolicyID {
+			accessPolicy = policy
 		}
 	}
-	if !accessPolicyFound {
+	if accessPolicy == nil {
 		return gapi.CloudAccessPolicyToken{}, fmt.Errorf("Access Policy not found")
 	}
+	if err := validateCloudName(input.Name); err != nil {
+		return gapi.CloudAccessPolicyToken{}, err
+	}
+	for _, token := range c.CloudAccessPolicyTokenItems {
+		if token.AccessPolicyID == input.AccessPolicyID && token.Name == input.Name {
+			return gapi.CloudAccessPolicyToken{}, apiError(http.StatusConflict, fmt.Sprintf("token %q already exists", input.Name))
+		}
+	}
+	if err := checkQuota(c.Quotas.TokensPerAccessPolicy, c.QuotaUsage().TokensPerAccessPolicy[input.AccessPolicyID]); err != nil {
+		return gapi.CloudAccessPolicyToken{}, err
+	}
 	token := gapi.CloudAccessPolicyToken{}
-	token.ID = fmt.Sprintf("%d", len(c.CloudAccessPolicyTokenItems)+1)
+	token.ID = c.nextCloudAccessPolicyTokenID()
 	token.AccessPolicyID = input.AccessPolicyID
 	token.Name = input.Name
 	token.DisplayName = input.DisplayName
 	token.ExpiresAt = input.ExpiresAt
-	token.CreatedAt = time.Now()
-	token.Token = "MockToken"
-	c.CloudAccessPolicyTokenItems = append(c.CloudAccessPolicyTokenItems, &token)
+	token.CreatedAt = c.now()
+
+	stored := token
+	secret := newCloudToken(fmt.Sprintf("%d", c.orgID()), fmt.Sprintf("%s-%s", accessPolicy.Name, input.Name), region)
+	if c.accessPolicyTokenHashes == nil {
+		c.accessPolicyTokenHashes = make(map[*gapi.CloudAccessPolicyToken]string)
+	}
+	c.accessPolicyTokenHashes[&stored] = hashSecret(secret)
+	c.CloudAccessPolicyTokenItems = append(c.CloudAccessPolicyTokenItems, &stored)
+
+	token.Token = secret
 	return token, nil
 }
 
+// CloudAccessPolicyTokenLastUsedAt returns when the fake Cloud Access Policy token that matches the given ID last
+// authenticated, or nil if it never has. gapi's CloudAccessPolicyToken only carries FirstUsedAt.
+func (c *MockClient) CloudAccessPolicyTokenLastUsedAt(region, id string) (*time.Time, error) {
+	if region == "" {
+		return nil, fmt.Errorf("region required")
+	}
+	for _, token := range c.CloudAccessPolicyTokenItems {
+		if token.ID == id {
+			lastUsedAt, ok := c.accessPolicyTokenLastUsed[token]
+			if !ok {
+				return nil, nil
+			}
+			return &lastUsedAt, nil
+		}
+	}
+	return nil, fmt.Errorf("token not found")
+}
+
+// UpdateCloudAccessPolicyToken will update the display name of the fake Cloud Access Policy token that matches the given ID
+func (c *MockClient) UpdateCloudAccessPolicyToken(region, id string, input gapi.UpdateCloudAccessPolicyTokenInput) (gapi.CloudAccessPolicyToken, error) {
+	if region == "" {
+		return gapi.CloudAccessPolicyToken{}, fmt.Errorf("region required")
+	}
+
+	for _, token := range c.CloudAccessPolicyTokenItems {
+		if token.ID == id {
+			if input.DisplayName != "" {
+				token.DisplayName = input.DisplayName
+			}
+			updatedAt := c.now()
+			token.UpdatedAt = &updatedAt
+			return *token, nil
+		}
+	}
+	return gapi.CloudAccessPolicyToken{}, fmt.Errorf("token not found")
+}
+
 // DeleteCloudAccessPolicyToken deletes the fake Cloud Access Policy token that matches the given ID
 func (c *MockClient) DeleteCloudAccessPolicyToken(region, id string) error {
 	if region == "" {
@@ -256,18 +556,67 @@ func (client *MockClient) CreateServiceAccount(request gapi.CreateServiceAccount
 			return nil, fmt.Errorf("service account name must be unique")
 		}
 	}
+	if request.Role != "" {
+		if err := validateRole(request.Role, InstanceRoles); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkQuota(client.Quotas.ServiceAccountsPerInstance, len(client.ServiceAccountsDTO)); err != nil {
+		return nil, err
+	}
 
 	serviceAccount := gapi.ServiceAccountDTO{
-		ID:     int64(len(client.ServiceAccountsDTO) + 1),
+		ID:     client.nextServiceAccountID(),
 		Name:   request.Name,
 		Login:  fmt.Sprintf("sa-%s", request.Name),
 		Role:   request.Role,
 		Tokens: 0,
 	}
+	if request.IsDisabled != nil {
+		serviceAccount.IsDisabled = *request.IsDisabled
+	}
 	client.ServiceAccountsDTO = append(client.ServiceAccountsDTO, serviceAccount)
 	return &serviceAccount, nil
 }
 
+// nextServiceAccountID returns an id above every current service account id, so a new account never shares its id
+// with an existing one after deletions
+func (client *MockClient) nextServiceAccountID() int64 {
+	var id int64
+	for _, sa := range client.ServiceAccountsDTO {
+		if sa.ID > id {
+			id = sa.ID
+		}
+	}
+	return id + 1
+}
+
+// countServiceAccountTokens sets the token count of the service account to the number of tokens it holds
+func (client *MockClient) countServiceAccountTokens(serviceAccountID int64) {
+	var count int64
+	for _, token := range client.Tokens {
+		if token.ServiceAccountID == serviceAccountID {
+			count++
+		}
+	}
+	for idx := range client.ServiceAccountsDTO {
+		if client.ServiceAccountsDTO[idx].ID == serviceAccountID {
+			client.ServiceAccountsDTO[idx].Tokens = count
+		}
+	}
+}
+
+// nextTokenID returns an id above every current service account token id
+func (client *MockClient) nextTokenID() int64 {
+	var id int64
+	for _, token := range client.Tokens {
+		if token.ID > id {
+			id = token.ID
+		}
+	}
+	return id + 1
+}
+
 // CreateServiceAccountToken is a Mock of the grafana api method, that will take a CreateServiceAccountTokenRequest and will create
 // and return the grafana service account token created.
 func (client *MockClient) CreateServiceAccountToken(request gapi.CreateServiceAccountTokenRequest) (*gapi.CreateServiceAccountTokenResponse, error) {
@@ -287,36 +636,154 @@ func (client *MockClient) CreateServiceAccountToken(request gapi.CreateServiceAc
 		}
 	}
 
+	key := newServiceAccountToken()
 	token := Token{
-		ID:               int64(len(client.Tokens) + 1),
+		ID:               client.nextTokenID(),
 		Name:             request.Name,
-		Created:          time.Now(),
+		Created:          client.now(),
 		ServiceAccountID: request.ServiceAccountID,
-		Key:              fmt.Sprintf("%s-%d", request.Name, int64(rand.Intn(99999))),
+		HashedKey:        hashSecret(key),
+		SecondsToLive:    request.SecondsToLive,
+	}
+	if request.SecondsToLive > 0 {
+		expiration := token.Created.Add(time.Duration(request.SecondsToLive) * time.Second)
+		token.Expiration = &expiration
 	}
 
 	client.Tokens = append(client.Tokens, token)
-
-	for _, sa := range client.ServiceAccountsDTO {
-		if sa.ID == request.ServiceAccountID {
-			sa.Tokens++
-		}
-	}
+	client.countServiceAccountTokens(request.ServiceAccountID)
 
 	return &gapi.CreateServiceAccountTokenResponse{
 		ID:   token.ID,
 		Name: token.Name,
-		Key:  token.Key,
+		Key:  key,
 	}, nil
 }
 
+// UpdateServiceAccount is a Mock of the grafana api method, that will take a serviceAccountID and an UpdateServiceAccountRequest
+// and apply the name, role and disabled state that were set on the request. Renaming regenerates the login.
+func (client *MockClient) UpdateServiceAccount(serviceAccountID int64, request gapi.UpdateServiceAccountRequest) (*gapi.ServiceAccountDTO, error) {
+	for idx := range client.ServiceAccountsDTO {
+		sa := &client.ServiceAccountsDTO[idx]
+		if sa.ID != serviceAccountID {
+			continue
+		}
+
+		if request.Role != "" {
+			if err := validateRole(request.Role, InstanceRoles); err != nil {
+				return nil, err
+			}
+		}
+		if request.Name != "" && request.Name != sa.Name {
+			for _, other := range client.ServiceAccountsDTO {
+				if other.Name == request.Name {
+					return nil, fmt.Errorf("service account name must be unique")
+				}
+			}
+			sa.Name = request.Name
+			sa.Login = fmt.Sprintf("sa-%s", request.Name)
+		}
+		if request.Role != "" {
+			sa.Role = request.Role
+		}
+		if request.IsDisabled != nil {
+			sa.IsDisabled = *request.IsDisabled
+		}
+
+		serviceAccount := *sa
+		return &serviceAccount, nil
+	}
+	return nil, fmt.Errorf("service account not found")
+}
+
 // GetServiceAccounts is a Mock of the grafana api method, that will list all service accounts
 func (client *MockClient) GetServiceAccounts() ([]gapi.ServiceAccountDTO, error) {
 	return client.ServiceAccountsDTO, nil
 }
 
+// Filters accepted by SearchServiceAccounts, matching the values of grafana's filter query parameter
+const (
+	ServiceAccountFilterDisabled      = "disabled"
+	ServiceAccountFilterExpiredTokens = "expiredTokens"
+)
+
+// SearchServiceAccountsRequest holds the query parameters of grafana's service account search
+type SearchServiceAccountsRequest struct {
+	Query   string
+	Filter  string
+	PerPage int64
+	Page    int64
+}
+
+// SearchServiceAccounts is a Mock of the grafana api search method. Query is matched case insensitively against the
+// name and login, Filter narrows the results to disabled accounts or accounts holding expired tokens, and
+// PerPage/Page select the page returned (defaulting to 1000 and 1 like grafana).
+func (client *MockClient) SearchServiceAccounts(request SearchServiceAccountsRequest) (*gapi.RetrieveServiceAccountResponse, error) {
+	if request.Filter != "" && request.Filter != ServiceAccountFilterDisabled && request.Filter != ServiceAccountFilterExpiredTokens {
+		return nil, fmt.Errorf("invalid filter %q", request.Filter)
+	}
+	perPage := request.PerPage
+	if perPage <= 0 {
+		perPage = 1000
+	}
+	page := request.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	query := strings.ToLower(request.Query)
+	matches := make([]gapi.ServiceAccountDTO, 0)
+	for _, sa := range client.ServiceAccountsDTO {
+		if query != "" && !strings.Contains(strings.ToLower(sa.Name), query) && !strings.Contains(strings.ToLower(sa.Login), query) {
+			continue
+		}
+		if request.Filter == ServiceAccountFilterDisabled && !sa.IsDisabled {
+			continue
+		}
+		if request.Filter == ServiceAccountFilterExpiredTokens && !client.hasExpiredTokens(sa.ID) {
+			continue
+		}
+		matches = append(matches, sa)
+	}
+
+	response := &gapi.RetrieveServiceAccountResponse{
+		TotalCount:      int64(len(matches)),
+		ServiceAccounts: make([]gapi.ServiceAccountDTO, 0),
+		Page:            page,
+		PerPage:         perPage,
+	}
+	start := (page - 1) * perPage
+	if start < int64(len(matches)) {
+		end := start + perPage
+		if end > int64(len(matches)) {
+			end = int64(len(matches))
+		}
+		response.ServiceAccounts = matches[start:end]
+	}
+	return response, nil
+}
+
+func (client *MockClient) hasExpiredTokens(serviceAccountID int64) bool {
+	for _, token := range client.Tokens {
+		if token.ServiceAccountID == serviceAccountID && token.Expiration != nil && token.Expiration.Before(client.now()) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetServiceAccountTokens is a Mock of the grafana api method, that will take a serviceAccountID and return a GetServiceAccountTokensResponse
 func (client *MockClient) GetServiceAccountTokens(serviceAccountID int64) ([]gapi.GetServiceAccountTokensResponse, error) {
+	var saFound bool
+	for _, sa := range client.ServiceAccountsDTO {
+		if sa.ID == serviceAccountID {
+			saFound = true
+		}
+	}
+	if !saFound {
+		return nil, fmt.Errorf("service account not found")
+	}
+
 	response := make([]gapi.GetServiceAccountTokensResponse, 0)
 
 	for _, token := range client.Tokens {
@@ -332,17 +799,52 @@ func (client *MockClient) GetServiceAccountTokens(serviceAccountID int64) ([]gap
 	return response, nil
 }
 
+// ServiceAccountTokenLastUsedAt returns when the service account token that matches the given IDs last authenticated,
+// or nil if it never has. gapi's GetServiceAccountTokensResponse doesn't carry it.
+func (client *MockClient) ServiceAccountTokenLastUsedAt(serviceAccountID, tokenID int64) (*time.Time, error) {
+	var saFound bool
+	for _, sa := range client.ServiceAccountsDTO {
+		if sa.ID == serviceAccountID {
+			saFound = true
+		}
+	}
+	if !saFound {
+		return nil, fmt.Errorf("service account not found")
+	}
+
+	for _, token := range client.Tokens {
+		if token.ServiceAccountID == serviceAccountID && token.ID == tokenID {
+			return token.LastUsedAt, nil
+		}
+	}
+	return nil, fmt.Errorf("token not found")
+}
+
 // DeleteServiceAccount is a Mock of the grafana api method, that will take a serviceAccountID and delete the service account
+// along with every token that belongs to it
 func (client *MockClient) DeleteServiceAccount(serviceAccountID int64) (*gapi.DeleteServiceAccountResponse, error) {
 	for idx, sa := range client.ServiceAccountsDTO {
 		if sa.ID == serviceAccountID {
 			client.ServiceAccountsDTO[idx] = client.ServiceAccountsDTO[len(client.ServiceAccountsDTO)-1]
 			client.ServiceAccountsDTO[len(client.ServiceAccountsDTO)-1] = gapi.ServiceAccountDTO{}
 			client.ServiceAccountsDTO = client.ServiceAccountsDTO[:len(client.ServiceAccountsDTO)-1]
-			return nil, nil
+
+			tokens := client.Tokens
+			tokenIdx := 0
+			for _, token := range tokens {
+				if token.ServiceAccountID != serviceAccountID {
+					tokens[tokenIdx] = token
+					tokenIdx++
+				}
+			}
+			client.Tokens = tokens[:tokenIdx]
+
+			return &gapi.DeleteServiceAccountResponse{
+				Message: "Service account deleted",
+			}, nil
 		}
 	}
-	return nil, fmt.Errorf("could not find token")
+	return nil, fmt.Errorf("service account not found")
 }
 
 // DeleteServiceAccountToken is a Mock of the grafana api method, that will take a serviceAccountID and tokenID, and deletes
@@ -370,6 +872,7 @@ func (client *MockClient) DeleteServiceAccountToken(serviceAccountID, tokenID in
 	if !tokenFound {
 		return nil, fmt.Errorf("token not found")
 	}
+	client.countServiceAccountTokens(serviceAccountID)
 	return nil, nil
 }
 
@@ -400,15 +903,24 @@ func (client *MockClient) CreateCloudAPIKey(org string, input *gapi.CreateCloudA
 			return nil, fmt.Errorf("cloud api key must be unique")
 		}
 	}
-	newKey := &gapi.CloudAPIKey{
-		ID:    len(client.CloudAPIKeys) + 1,
-		Name:  input.Name,
-		Role:  input.Role,
-		Token: fmt.Sprintf("%v-%v", input.Name, rand.Intn(99999)),
+	if err := validateRole(input.Role, CloudAPIKeyRoles); err != nil {
+		return nil, err
+	}
+	stored := &gapi.CloudAPIKey{
+		ID:   len(client.CloudAPIKeys) + 1,
+		Name: input.Name,
+		Role: input.Role,
 	}
+	secret := newCloudToken(fmt.Sprintf("%d", client.orgID()), input.Name, "")
+	if client.cloudAPIKeyHashes == nil {
+		client.cloudAPIKeyHashes = make(map[*gapi.CloudAPIKey]string)
+	}
+	client.cloudAPIKeyHashes[stored] = hashSecret(secret)
+	client.CloudAPIKeys = append(client.CloudAPIKeys, stored)
 
-	client.CloudAPIKeys = append(client.CloudAPIKeys, newKey)
-	return newKey, nil
+	newKey := *stored
+	newKey.Token = secret
+	return &newKey, nil
 }
 
 // GenerateCloudAPIKeys generates x number of APIKeys (x specified by count) with an option prefix and role.
@@ -439,7 +951,7 @@ func (client *MockClient) GenerateCloudAPIKey(name, role string) (*gapi.CloudAPI
 	}
 
 	if role == "" {
-		role = RoleGenerator()
+		role = CloudAPIKeyRoleGenerator()
 	}
 	tokenRequest := gapi.CreateCloudAPIKeyInput{
 		Name: name,
@@ -507,13 +1019,24 @@ func (client *MockClient) GenerateServiceAccount(name, role string) (*gapi.Servi
 	return client.CreateServiceAccount(request)
 }
 
-// RoleGenerator returns a random role from the list
+// RoleGenerator returns a random grafana instance role for service accounts and legacy api keys. Use
+// CloudAPIKeyRoleGenerator for cloud api keys.
 func RoleGenerator() string {
 	rand.Seed(time.Now().UnixNano())
-	roles := []string{"Admin", "Viewer", "Editor", "MetricsPublisher"}
+	roles := []string{"Admin", "Viewer", "Editor"}
 	return roles[rand.Intn(len(roles))]
 }
 
+// uniqueName returns prefix followed by the lowest numeric suffix for which taken reports false
+func uniqueName(prefix string, taken func(string) bool) string {
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s-%d", prefix, i)
+		if !taken(name) {
+			return name
+		}
+	}
+}
+
 // StringGenerator returns a random string
 func StringGenerator(seed int) string {
 	rand.Seed(time.Now().UnixNano() + int64(seed))
@@ -5,15 +5,48 @@ import (
 	"log"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-api-golang-client"
 )
 
+func TestInitialize(t *testing.T) {
+	t.Run("should accept token of an enabled service account", func(t *testing.T) {
+		client := NewClient()
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		if err := client.Initialize(token.Key, ""); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("should reject token of a disabled service account", func(t *testing.T) {
+		client := NewClient()
+
+		disabled := true
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+		client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{IsDisabled: &disabled})
+
+		if err := client.Initialize(token.Key, ""); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+
+		serviceAccounts, _ := client.GetServiceAccounts()
+		if len(serviceAccounts) != 1 {
+			t.Errorf("expected disabled service account to still be listed, got %v", serviceAccounts)
+		}
+	})
+}
+
 func TestCreateCloudAccessPolicy(t *testing.T) {
 	t.Run("should not create access policy if invalid type", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		realmTypeArg := "InvalidType"
 		realmIdentifierArg := "clabs"
 		policyRealmsArg := NewRealm(realmTypeArg, realmIdentifierArg, `{env="dev"}`)
@@ -34,9 +67,121 @@ func TestCreateCloudAccessPolicy(t *testing.T) {
 		}
 	})
 
+	t.Run("should not create access policy with invalid name", func(t *testing.T) {
+		client := NewClient()
+		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
+		regionArg := "us"
+
+		for _, name := range []string{"", "TestPolicyName", "test_policy", "-test", "test-", "test policy"} {
+			input := gapi.CreateCloudAccessPolicyInput{
+				Name:        name,
+				DisplayName: "Test Policy",
+				Scopes:      []string{"metrics:read"},
+				Realms:      []gapi.CloudAccessPolicyRealm{policyRealmsArg},
+			}
+
+			_, err := client.CreateCloudAccessPolicy(regionArg, input)
+
+			if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+				t.Errorf("expected a bad request error for %q but got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("should not create access policy with duplicate name", func(t *testing.T) {
+		client := NewClient()
+		policyNameArg := "test-policy-name"
+		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
+		regionArg := "us"
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:        policyNameArg,
+			DisplayName: "Test Policy",
+			Scopes:      []string{"metrics:read"},
+			Realms:      []gapi.CloudAccessPolicyRealm{policyRealmsArg},
+		}
+
+		client.CreateCloudAccessPolicy(regionArg, input)
+		_, err := client.CreateCloudAccessPolicy(regionArg, input)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error but got %v", err)
+		}
+	})
+
+	t.Run("should not create access policy with malformed label policy", func(t *testing.T) {
+		client := NewClient()
+		policyNameArg := "test-policy-name"
+		policyRealmsArg := NewRealm("stack", "clabs", `{env="dev", team=~"infra.*"}`, `{env=dev}`)
+		regionArg := "us"
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:        policyNameArg,
+			DisplayName: policyNameArg,
+			Scopes:      []string{"metrics:read"},
+			Realms:      []gapi.CloudAccessPolicyRealm{policyRealmsArg},
+		}
+
+		_, err := client.CreateCloudAccessPolicy(regionArg, input)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+		if len(client.CloudAccessPolicyItems) > 0 {
+			t.Errorf("expected no access policies but found %v", client.CloudAccessPolicyItems)
+		}
+	})
+
+	t.Run("should not create access policy with unknown scope in strict mode", func(t *testing.T) {
+		client := NewClient()
+		client.Strict = true
+		stack, _ := client.GenerateStack("clabs")
+		policyNameArg := "test-policy-name"
+		policyRealmsArg := NewRealm("stack", fmt.Sprintf("%d", stack.ID), `{env="dev"}`)
+		regionArg := "us"
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:        policyNameArg,
+			DisplayName: policyNameArg,
+			Scopes:      []string{"metrics:read", "testScope"},
+			Realms:      []gapi.CloudAccessPolicyRealm{policyRealmsArg},
+		}
+
+		_, err := client.CreateCloudAccessPolicy(regionArg, input)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+
+		input.Scopes = []string{"metrics:read", "logs:write"}
+		if _, err := client.CreateCloudAccessPolicy(regionArg, input); err != nil {
+			t.Errorf("expected no error for catalogue scopes but got %v", err)
+		}
+	})
+
+	t.Run("should create access policy with label policy on org realm", func(t *testing.T) {
+		client := NewClient()
+		policyNameArg := "test-policy-name"
+		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
+		regionArg := "us"
+
+		input := gapi.CreateCloudAccessPolicyInput{
+			Name:        policyNameArg,
+			DisplayName: policyNameArg,
+			Scopes:      []string{"metrics:read"},
+			Realms:      []gapi.CloudAccessPolicyRealm{policyRealmsArg},
+		}
+
+		_, err := client.CreateCloudAccessPolicy(regionArg, input)
+
+		if err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
 	t.Run("should return error if region doesn't exist", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		realmTypeArg := "stack"
 		realmIdentifierArg := "clabs"
 		policyRealmsArg := NewRealm(realmTypeArg, realmIdentifierArg, `{env="dev"}`)
@@ -59,7 +204,7 @@ func TestCreateCloudAccessPolicy(t *testing.T) {
 
 	t.Run("should create access policy", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -84,7 +229,7 @@ func TestCreateCloudAccessPolicy(t *testing.T) {
 func TestCloudAccessPolicies(t *testing.T) {
 	t.Run("should return error if no region ", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -108,7 +253,7 @@ func TestCloudAccessPolicies(t *testing.T) {
 
 	t.Run("should list access policies", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -133,10 +278,111 @@ func TestCloudAccessPolicies(t *testing.T) {
 	})
 }
 
+func TestUpdateCloudAccessPolicy(t *testing.T) {
+	t.Run("should return error if policy doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.UpdateCloudAccessPolicy("us", "3", gapi.UpdateCloudAccessPolicyInput{DisplayName: "new"})
+
+		if err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+
+	t.Run("should return error if no region", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		_, err := client.UpdateCloudAccessPolicy("", policy.ID, gapi.UpdateCloudAccessPolicyInput{DisplayName: "new"})
+
+		if err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+
+	t.Run("should update display name, scopes and realms", func(t *testing.T) {
+		client := NewClient()
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		now = now.Add(time.Hour)
+		input := gapi.UpdateCloudAccessPolicyInput{
+			DisplayName: "New Display Name",
+			Scopes:      []string{"logs:read"},
+			Realms:      []gapi.CloudAccessPolicyRealm{NewRealm("stack", "clabs", `{env="prod"}`)},
+		}
+		updated, err := client.UpdateCloudAccessPolicy("us", policy.ID, input)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		got := client.CloudAccessPolicyItems[0]
+		if got.DisplayName != input.DisplayName || !reflect.DeepEqual(got.Scopes, input.Scopes) || !reflect.DeepEqual(got.Realms, input.Realms) {
+			t.Errorf("got %+v want fields from %+v", got, input)
+		}
+		if got.Name != "test-policy-name" {
+			t.Errorf("expected name to be unchanged, got %q", got.Name)
+		}
+		if !updated.UpdatedAt.Equal(now) {
+			t.Errorf("got updatedAt %v want %v", updated.UpdatedAt, now)
+		}
+	})
+
+	t.Run("should update the policy created after a delete", func(t *testing.T) {
+		client := NewClient()
+		a := client.GenerateCloudAccessPolicy("policy-a")
+		b := client.GenerateCloudAccessPolicy("policy-b")
+		client.DeleteCloudAccessPolicy("us", a.ID)
+		c := client.GenerateCloudAccessPolicy("policy-c")
+
+		if c.ID == b.ID {
+			t.Fatalf("expected a new id but got %q, already used by policy-b", c.ID)
+		}
+		client.UpdateCloudAccessPolicy("us", c.ID, gapi.UpdateCloudAccessPolicyInput{DisplayName: "New Display Name"})
+
+		for _, policy := range client.CloudAccessPolicyItems {
+			if want := policy.Name == "policy-c"; (policy.DisplayName == "New Display Name") != want {
+				t.Errorf("got display name %q for %s", policy.DisplayName, policy.Name)
+			}
+		}
+	})
+
+	t.Run("should not update policy with invalid realms", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		realms := policy.Realms
+
+		input := gapi.UpdateCloudAccessPolicyInput{
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", "clabs", `{env=prod}`)},
+		}
+		_, err := client.UpdateCloudAccessPolicy("us", policy.ID, input)
+
+		if err == nil {
+			t.Errorf("expected error but got none")
+		}
+		if !reflect.DeepEqual(client.CloudAccessPolicyItems[0].Realms, realms) {
+			t.Errorf("expected realms to be unchanged, got %+v", client.CloudAccessPolicyItems[0].Realms)
+		}
+	})
+
+	t.Run("should not update policy with unknown scopes in strict mode", func(t *testing.T) {
+		client := NewClient()
+		client.Strict = true
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		_, err := client.UpdateCloudAccessPolicy("us", policy.ID, gapi.UpdateCloudAccessPolicyInput{Scopes: []string{"testScope"}})
+
+		if err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+}
+
 func TestDeleteCloudAccessPolicy(t *testing.T) {
 	t.Run("should delete policy if it exists", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -157,7 +403,7 @@ func TestDeleteCloudAccessPolicy(t *testing.T) {
 	})
 	t.Run("should delete tokens from policy", func(t *testing.T) {
     	client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -180,7 +426,7 @@ func TestDeleteCloudAccessPolicy(t *testing.T) {
 
 	t.Run("should return error if region doesn't exist", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
+		policyNameArg := "test-policy-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -205,7 +451,7 @@ func TestDeleteCloudAccessPolicy(t *testing.T) {
 func TestCreateCloudAccessPolicyToken(t *testing.T) {
 	t.Run("should return error if access policy doesn't exist", func(t *testing.T) {
 		client := NewClient()
-		tokenNameArg := "TestTokenName"
+		tokenNameArg := "test-token-name"
 
 		tokenInput := gapi.CreateCloudAccessPolicyTokenInput{
 			AccessPolicyID: "3",
@@ -222,8 +468,8 @@ func TestCreateCloudAccessPolicyToken(t *testing.T) {
 
 	t.Run("should return error if region doesn't exist", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -252,8 +498,8 @@ func TestCreateCloudAccessPolicyToken(t *testing.T) {
 
 	t.Run("should create access policy token", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -273,12 +519,103 @@ func TestCreateCloudAccessPolicyToken(t *testing.T) {
 			DisplayName:    tokenNameArg,
 		}
 
-		client.CreateCloudAccessPolicyToken(regionArg, tokenInput)
-		want := "MockToken"
-		got := client.CloudAccessPolicyTokenItems[0].Token
+		token, _ := client.CreateCloudAccessPolicyToken(regionArg, tokenInput)
+		decoded, err := DecodeToken(token.Token)
+		if err != nil {
+			t.Fatalf("expected a decodable token, got %v", err)
+		}
 
-		if got != want {
-			t.Errorf("got %v want %v", got, want)
+		want := DecodedToken{
+			Type:   TokenTypeCloud,
+			Name:   policyNameArg + "-" + tokenNameArg,
+			OrgID:  "1",
+			Region: regionArg,
+			Secret: decoded.Secret,
+		}
+		if *decoded != want {
+			t.Errorf("got %+v want %+v", *decoded, want)
+		}
+	})
+}
+
+func TestCloudAccessPolicyTokenSecrets(t *testing.T) {
+	t.Run("should not return the secret after creation", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		token, _ := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "test-token-name",
+		})
+		if token.Token == "" {
+			t.Fatalf("expected the create response to include the secret")
+		}
+
+		foundToken, _ := client.CloudAccessPolicyTokenByID("us", token.ID)
+		if foundToken.Token != "" {
+			t.Errorf("expected no secret from CloudAccessPolicyTokenByID but got %q", foundToken.Token)
+		}
+
+		items, _ := client.CloudAccessPolicyTokens("us", policy.ID)
+		for _, item := range items.Items {
+			if item.Token != "" {
+				t.Errorf("expected no secret from CloudAccessPolicyTokens but got %q", item.Token)
+			}
+		}
+
+		updated, _ := client.UpdateCloudAccessPolicyToken("us", token.ID, gapi.UpdateCloudAccessPolicyTokenInput{DisplayName: "new"})
+		if updated.Token != "" {
+			t.Errorf("expected no secret from UpdateCloudAccessPolicyToken but got %q", updated.Token)
+		}
+
+		if _, err := client.LookupToken(token.Token); err != nil {
+			t.Errorf("expected the secret to still authenticate but got %v", err)
+		}
+	})
+}
+
+func TestCloudAccessPolicyTokenNames(t *testing.T) {
+	t.Run("should not create token with invalid name", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		tokenInput := gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "TestTokenName",
+		}
+		_, err := client.CreateCloudAccessPolicyToken("us", tokenInput)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+
+	t.Run("should not create token with duplicate name in the same policy", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+
+		tokenInput := gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "test-token-name",
+		}
+		client.CreateCloudAccessPolicyToken("us", tokenInput)
+		_, err := client.CreateCloudAccessPolicyToken("us", tokenInput)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 409") {
+			t.Errorf("expected a conflict error but got %v", err)
+		}
+	})
+
+	t.Run("should allow the same token name in different policies", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		policy2 := client.GenerateCloudAccessPolicy("other-policy-name")
+
+		client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{AccessPolicyID: policy.ID, Name: "test-token-name"})
+		_, err := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{AccessPolicyID: policy2.ID, Name: "test-token-name"})
+
+		if err != nil {
+			t.Errorf("expected no error but got %v", err)
 		}
 	})
 }
@@ -286,8 +623,8 @@ func TestCreateCloudAccessPolicyToken(t *testing.T) {
 func TestCloudAccessPolicyTokenByID(t *testing.T) {
 	t.Run("should return error if no region ", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -316,8 +653,8 @@ func TestCloudAccessPolicyTokenByID(t *testing.T) {
 
 	t.Run("should get access policy token", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -351,8 +688,8 @@ func TestCloudAccessPolicyTokenByID(t *testing.T) {
 func TestCloudAccessPolicyTokens(t *testing.T) {
 	t.Run("should return error if no region ", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -381,8 +718,8 @@ func TestCloudAccessPolicyTokens(t *testing.T) {
 
 	t.Run("should not return anything if access policy ID is empty", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -411,8 +748,8 @@ func TestCloudAccessPolicyTokens(t *testing.T) {
 
 	t.Run("should list access policy tokens", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -443,6 +780,59 @@ func TestCloudAccessPolicyTokens(t *testing.T) {
 	})
 }
 
+func TestUpdateCloudAccessPolicyToken(t *testing.T) {
+	t.Run("should return error if token doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.UpdateCloudAccessPolicyToken("us", "3", gapi.UpdateCloudAccessPolicyTokenInput{DisplayName: "new"})
+
+		if err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+
+	t.Run("should update display name", func(t *testing.T) {
+		client := NewClient()
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		token := client.GenerateCloudAccessPolicyToken("test-token-name", policy.ID)
+
+		now = now.Add(time.Hour)
+		updated, err := client.UpdateCloudAccessPolicyToken("us", token.ID, gapi.UpdateCloudAccessPolicyTokenInput{DisplayName: "New Display Name"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if client.CloudAccessPolicyTokenItems[0].DisplayName != "New Display Name" {
+			t.Errorf("got display name %q", client.CloudAccessPolicyTokenItems[0].DisplayName)
+		}
+		if updated.UpdatedAt == nil || !updated.UpdatedAt.Equal(now) {
+			t.Errorf("got updatedAt %v want %v", updated.UpdatedAt, now)
+		}
+	})
+
+	t.Run("should update the token created after a delete", func(t *testing.T) {
+		client := NewClient()
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		t1 := client.GenerateCloudAccessPolicyToken("token-1", policy.ID)
+		t2 := client.GenerateCloudAccessPolicyToken("token-2", policy.ID)
+		client.DeleteCloudAccessPolicyToken("us", t1.ID)
+		t3 := client.GenerateCloudAccessPolicyToken("token-3", policy.ID)
+
+		if t3.ID == t2.ID {
+			t.Fatalf("expected a new id but got %q, already used by token-2", t3.ID)
+		}
+		client.UpdateCloudAccessPolicyToken("us", t3.ID, gapi.UpdateCloudAccessPolicyTokenInput{DisplayName: "New Display Name"})
+
+		for _, token := range client.CloudAccessPolicyTokenItems {
+			if want := token.Name == "token-3"; (token.DisplayName == "New Display Name") != want {
+				t.Errorf("got display name %q for %s", token.DisplayName, token.Name)
+			}
+		}
+	})
+}
+
 func TestDeleteCloudAccessPolicyToken(t *testing.T) {
 	t.Run("should return error if token doesn't exist", func(t *testing.T) {
 		client := NewClient()
@@ -454,8 +844,8 @@ func TestDeleteCloudAccessPolicyToken(t *testing.T) {
 
 	t.Run("should return error if region doesn't exist", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -485,8 +875,8 @@ func TestDeleteCloudAccessPolicyToken(t *testing.T) {
 
 	t.Run("should delete token from the access policy", func(t *testing.T) {
 		client := NewClient()
-		policyNameArg := "TestPolicyName"
-		tokenNameArg := "TestTokenName"
+		policyNameArg := "test-policy-name"
+		tokenNameArg := "test-token-name"
 		policyRealmsArg := NewRealm("org", "clabs", `{env="dev"}`)
 		policyScopesArg := []string{"testScope"}
 		regionArg := "us"
@@ -563,6 +953,22 @@ func TestCreateServiceAccount(t *testing.T) {
 		}
 	})
 
+	t.Run("service account should be created disabled if requested", func(t *testing.T) {
+		client := NewClient()
+
+		disabled := true
+		request := gapi.CreateServiceAccountRequest{
+			Name:       StringGenerator(0),
+			Role:       RoleGenerator(),
+			IsDisabled: &disabled,
+		}
+		client.CreateServiceAccount(request)
+
+		if !client.ServiceAccountsDTO[0].IsDisabled {
+			t.Errorf("expected service account to be disabled")
+		}
+	})
+
 	t.Run("service account should have correct role", func(t *testing.T) {
 		client := NewClient()
 
@@ -582,6 +988,22 @@ func TestCreateServiceAccount(t *testing.T) {
 }
 
 func TestCreateServiceAccountToken(t *testing.T) {
+	t.Run("should count the service account's tokens", func(t *testing.T) {
+		client := NewClient()
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		tokens, _ := client.GenerateServiceAccountTokens(sa.ID, 2)
+
+		if got := client.ServiceAccountsDTO[0].Tokens; got != 2 {
+			t.Errorf("got %d tokens want 2", got)
+		}
+
+		client.DeleteServiceAccountToken(sa.ID, tokens[0].ID)
+		if got := client.ServiceAccountsDTO[0].Tokens; got != 1 {
+			t.Errorf("got %d tokens after deletion want 1", got)
+		}
+	})
+
 	t.Run("should return error if service account doesn't exist", func(t *testing.T) {
 		client := NewClient()
 
@@ -679,18 +1101,186 @@ func TestCreateServiceAccountToken(t *testing.T) {
 			Name:             StringGenerator(0),
 			ServiceAccountID: sa.ID,
 		}
-		client.CreateServiceAccountToken(tokenRequest)
+		response, _ := client.CreateServiceAccountToken(tokenRequest)
+
+		if response.Key == "" {
+			t.Errorf("expected a key in the create response but got none")
+		}
+		if client.Tokens[0].HashedKey == "" || client.Tokens[0].HashedKey == response.Key {
+			t.Errorf("expected only a hash of the key to be stored but got %v", client.Tokens[0].HashedKey)
+		}
+	})
+}
+
+func TestUpdateServiceAccount(t *testing.T) {
+	t.Run("should return error if service account doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		nonexistentID := int64(rand.Intn(1000))
+		_, err := client.UpdateServiceAccount(nonexistentID, gapi.UpdateServiceAccountRequest{Role: "Viewer"})
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should return error if new name is taken", func(t *testing.T) {
+		client := NewClient()
 
-		want := true
-		got := client.Tokens[0].Key != "" || len(client.Tokens[0].Key) == 0
+		sa, _ := client.GenerateServiceAccount("", "")
+		sa2, _ := client.GenerateServiceAccount("", "")
+
+		_, err := client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{Name: sa2.Name})
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should rename service account and regenerate login", func(t *testing.T) {
+		client := NewClient()
+
+		arg := "renamed"
+		sa, _ := client.GenerateServiceAccount("", "")
+
+		updated, _ := client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{Name: arg})
+
+		if updated.Name != arg {
+			t.Errorf("got name %q want %q", updated.Name, arg)
+		}
+
+		want := "sa-" + arg
+		got := client.ServiceAccountsDTO[0].Login
 
 		if got != want {
-			t.Errorf("expected a string but got %v", client.Tokens[0].Key)
+			t.Errorf("got login %q want %q", got, want)
+		}
+	})
+
+	t.Run("should demote and disable service account", func(t *testing.T) {
+		client := NewClient()
+
+		disabled := true
+		sa, _ := client.GenerateServiceAccount("", "Admin")
+
+		client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{
+			Role:       "Viewer",
+			IsDisabled: &disabled,
+		})
+
+		got := client.ServiceAccountsDTO[0]
+
+		if got.Role != "Viewer" {
+			t.Errorf("got role %q want %q", got.Role, "Viewer")
+		}
+		if !got.IsDisabled {
+			t.Errorf("expected service account to be disabled")
+		}
+		if got.Name != sa.Name {
+			t.Errorf("expected name %q to be unchanged, got %q", sa.Name, got.Name)
+		}
+	})
+}
+
+func TestSearchServiceAccounts(t *testing.T) {
+	t.Run("should match query against name and login", func(t *testing.T) {
+		client := NewClient()
+
+		client.GenerateServiceAccount("deploy-bot", "")
+		client.GenerateServiceAccount("metrics-reader", "")
+		client.GenerateServiceAccounts(5)
+
+		response, _ := client.SearchServiceAccounts(SearchServiceAccountsRequest{Query: "sa-DEPLOY"})
+
+		if response.TotalCount != 1 || response.ServiceAccounts[0].Name != "deploy-bot" {
+			t.Errorf("expected only deploy-bot, got %+v", response)
+		}
+	})
+
+	t.Run("should page results", func(t *testing.T) {
+		client := NewClient()
+
+		client.GenerateServiceAccounts(5)
+
+		response, _ := client.SearchServiceAccounts(SearchServiceAccountsRequest{PerPage: 2, Page: 3})
+
+		if response.TotalCount != 5 {
+			t.Errorf("got total count %d want %d", response.TotalCount, 5)
+		}
+		if len(response.ServiceAccounts) != 1 || response.ServiceAccounts[0].ID != client.ServiceAccountsDTO[4].ID {
+			t.Errorf("expected last service account on page 3, got %+v", response.ServiceAccounts)
+		}
+		if response.Page != 3 || response.PerPage != 2 {
+			t.Errorf("got page %d perPage %d want 3 and 2", response.Page, response.PerPage)
+		}
+	})
+
+	t.Run("should filter disabled service accounts", func(t *testing.T) {
+		client := NewClient()
+
+		disabled := true
+		sa, _ := client.GenerateServiceAccount("", "")
+		client.GenerateServiceAccounts(3)
+		client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{IsDisabled: &disabled})
+
+		response, _ := client.SearchServiceAccounts(SearchServiceAccountsRequest{Filter: ServiceAccountFilterDisabled})
+
+		if response.TotalCount != 1 || response.ServiceAccounts[0].ID != sa.ID {
+			t.Errorf("expected only service account %d, got %+v", sa.ID, response.ServiceAccounts)
+		}
+	})
+
+	t.Run("should filter service accounts with expired tokens", func(t *testing.T) {
+		client := NewClient()
+
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		sa2, _ := client.GenerateServiceAccount("", "")
+		client.CreateServiceAccountToken(gapi.CreateServiceAccountTokenRequest{
+			Name:             "short-lived",
+			ServiceAccountID: sa.ID,
+			SecondsToLive:    60,
+		})
+		client.GenerateServiceAccountToken("", sa2.ID)
+
+		response, _ := client.SearchServiceAccounts(SearchServiceAccountsRequest{Filter: ServiceAccountFilterExpiredTokens})
+		if response.TotalCount != 0 {
+			t.Errorf("expected no service accounts before expiry, got %+v", response.ServiceAccounts)
+		}
+
+		now = now.Add(2 * time.Minute)
+
+		response, _ = client.SearchServiceAccounts(SearchServiceAccountsRequest{Filter: ServiceAccountFilterExpiredTokens})
+		if response.TotalCount != 1 || response.ServiceAccounts[0].ID != sa.ID {
+			t.Errorf("expected only service account %d, got %+v", sa.ID, response.ServiceAccounts)
+		}
+	})
+
+	t.Run("should return error for unknown filter", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.SearchServiceAccounts(SearchServiceAccountsRequest{Filter: "unknown"})
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
 		}
 	})
 }
 
 func TestGetServiceAccountTokens(t *testing.T) {
+	t.Run("should return error if service account doesn't exist", func(t *testing.T) {
+		client := NewClient()
+
+		nonexistentID := int64(rand.Intn(1000))
+		_, err := client.GetServiceAccountTokens(nonexistentID)
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
 	t.Run("should return the right number of tokens for the service account", func(t *testing.T) {
 		client := NewClient()
 
@@ -794,6 +1384,101 @@ func TestDeleteServiceAccount(t *testing.T) {
 			t.Errorf("Expected to have %d service account(s) after deletion, but got %d", want, got)
 		}
 	})
+
+	t.Run("tokens of the service account should be deleted", func(t *testing.T) {
+		client := NewClient()
+
+		countArg := 5
+		sa, _ := client.GenerateServiceAccount("", "")
+		sa2, _ := client.GenerateServiceAccount("", "")
+		client.GenerateServiceAccountTokens(sa.ID, countArg)
+		client.GenerateServiceAccountTokens(sa2.ID, countArg)
+
+		client.DeleteServiceAccount(sa.ID)
+
+		for _, token := range client.Tokens {
+			if token.ServiceAccountID == sa.ID {
+				t.Errorf("expected token %v to be deleted with service account %v", token.Name, sa.ID)
+			}
+		}
+
+		want := countArg
+		got := len(client.Tokens)
+
+		if got != want {
+			t.Errorf("Expected to have %d token(s) after deletion, but got %d", want, got)
+		}
+	})
+
+	t.Run("token lookups should fail after deletion", func(t *testing.T) {
+		client := NewClient()
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		client.DeleteServiceAccount(sa.ID)
+
+		if _, err := client.GetServiceAccountTokens(sa.ID); err == nil {
+			t.Errorf("expected an error listing tokens, got none")
+		}
+		if _, err := client.DeleteServiceAccountToken(sa.ID, token.ID); err == nil {
+			t.Errorf("expected an error deleting token, got none")
+		}
+	})
+
+	t.Run("recreated service account should not take an existing id", func(t *testing.T) {
+		client := NewClient()
+
+		a, _ := client.GenerateServiceAccount("a", "")
+		b, _ := client.GenerateServiceAccount("b", "")
+		client.GenerateServiceAccountToken("b-token", b.ID)
+		client.DeleteServiceAccount(a.ID)
+		d, _ := client.GenerateServiceAccount("d", "")
+
+		if d.ID == b.ID {
+			t.Fatalf("expected recreated service account to get a new id but it reused %d", b.ID)
+		}
+		client.DeleteServiceAccount(d.ID)
+
+		if len(client.ServiceAccountsDTO) != 1 || client.ServiceAccountsDTO[0].Name != "b" {
+			t.Errorf("expected only b to remain but found %v", client.ServiceAccountsDTO)
+		}
+		if len(client.Tokens) != 1 || client.Tokens[0].ServiceAccountID != b.ID {
+			t.Errorf("expected b's token to remain but found %v", client.Tokens)
+		}
+	})
+
+	t.Run("recreated token should not take an existing id", func(t *testing.T) {
+		client := NewClient()
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		first, _ := client.GenerateServiceAccountToken("first", sa.ID)
+		second, _ := client.GenerateServiceAccountToken("second", sa.ID)
+		client.DeleteServiceAccountToken(sa.ID, first.ID)
+		third, _ := client.GenerateServiceAccountToken("third", sa.ID)
+
+		if third.ID == second.ID {
+			t.Errorf("expected recreated token to get a new id but it reused %d", second.ID)
+		}
+	})
+
+	t.Run("should return a response message", func(t *testing.T) {
+		client := NewClient()
+
+		sa, _ := client.GenerateServiceAccount("", "")
+		response, err := client.DeleteServiceAccount(sa.ID)
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		want := "Service account deleted"
+		got := response.Message
+
+		if got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	})
 }
 
 func TestDeleteServiceAccountToken(t *testing.T) {
@@ -921,6 +1606,26 @@ func TestCreateCloudAPIKey(t *testing.T) {
 	})
 }
 
+func TestCloudAPIKeySecrets(t *testing.T) {
+	t.Run("should not return the secret after creation", func(t *testing.T) {
+		client := NewClient()
+
+		key, _ := client.CreateCloudAPIKey("", &gapi.CreateCloudAPIKeyInput{Name: "test-key", Role: "Admin"})
+		if key.Token == "" {
+			t.Fatalf("expected the create response to include the secret")
+		}
+
+		keys, _ := client.ListCloudAPIKeys("")
+		if keys.Items[0].Token != "" {
+			t.Errorf("expected no secret from ListCloudAPIKeys but got %q", keys.Items[0].Token)
+		}
+
+		if _, err := client.LookupToken(key.Token); err != nil {
+			t.Errorf("expected the secret to still authenticate but got %v", err)
+		}
+	})
+}
+
 func TestListCloudAPIKeys(t *testing.T) {
 	t.Run("created keys should have correct prefix", func(t *testing.T) {
 		client := NewClient()
@@ -1062,10 +1767,10 @@ func TestGenerateCloudAPIKey(t *testing.T) {
 func TestGenerateCloudAccessPolicy(t *testing.T) {
 	t.Run("should generate policy and add it to the client", func(t *testing.T) {
 		client := NewClient()
-		nameArg := "TestName"
+		nameArg := "test-name"
 		count := 100
 		for i := 1; i <= count; i++ {
-			client.GenerateCloudAccessPolicy(fmt.Sprintf("%v-%d", nameArg, count))
+			client.GenerateCloudAccessPolicy(fmt.Sprintf("%v-%d", nameArg, i))
 		}
 
 		want := count
@@ -1077,7 +1782,7 @@ func TestGenerateCloudAccessPolicy(t *testing.T) {
 
 	t.Run("should generate policy with the correct name", func(t *testing.T) {
 		client := NewClient()
-		nameArg := "TestName"
+		nameArg := "test-name"
 
 		want := nameArg
 		got := client.GenerateCloudAccessPolicy(nameArg).Name
@@ -1089,7 +1794,7 @@ func TestGenerateCloudAccessPolicy(t *testing.T) {
 
 	t.Run("should generate policy and return it", func(t *testing.T) {
 		client := NewClient()
-		nameArg := "TestName"
+		nameArg := "test-name"
 
 		want := "*gapi.CloudAccessPolicy"
 		got := client.GenerateCloudAccessPolicy(nameArg)
@@ -1098,12 +1803,33 @@ func TestGenerateCloudAccessPolicy(t *testing.T) {
 			t.Errorf("got %q want %q", got, want)
 		}
 	})
+
+	t.Run("should turn an invalid name into a slug", func(t *testing.T) {
+		client := NewClient()
+
+		got := client.GenerateCloudAccessPolicy("Not A Slug")
+		if got == nil || got.Name != "not-a-slug" {
+			t.Errorf("got %+v want a policy named not-a-slug", got)
+		}
+	})
+
+	t.Run("should not generate policy with a duplicate name", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateCloudAccessPolicy("test-name")
+
+		if got := client.GenerateCloudAccessPolicy("test-name"); got != nil {
+			t.Errorf("expected no policy for a duplicate name but got %+v", got)
+		}
+		if len(client.CloudAccessPolicyItems) != 1 {
+			t.Errorf("got %d policies want 1", len(client.CloudAccessPolicyItems))
+		}
+	})
 }
 
 func TestGenerateCloudAccessPolicies(t *testing.T) {
 	t.Run("should generate the specified number of policies", func(t *testing.T) {
 		client := NewClient()
-		nameArg := "TestName"
+		nameArg := "test-name"
 		count := 10
 		client.GenerateCloudAccessPolicies(count, nameArg)
 
@@ -1114,12 +1840,36 @@ func TestGenerateCloudAccessPolicies(t *testing.T) {
 			t.Errorf("got %v policies but want %v", got, want)
 		}
 	})
+
+	t.Run("should give each policy a unique name", func(t *testing.T) {
+		client := NewClient()
+		client.GenerateCloudAccessPolicies(3, "dup")
+		client.GenerateCloudAccessPolicies(2, "dup")
+
+		names := map[string]bool{}
+		for _, policy := range client.CloudAccessPolicyItems {
+			names[policy.Name] = true
+		}
+		if len(names) != 5 {
+			t.Errorf("expected 5 unique names but got %v", names)
+		}
+	})
+
+	t.Run("should slugify the prefix", func(t *testing.T) {
+		client := NewClient()
+
+		policies := client.GenerateCloudAccessPolicies(2, "Upper")
+
+		if len(policies) != 2 || policies[0].Name != "upper-1" || policies[1].Name != "upper-2" {
+			t.Errorf("got %v want policies upper-1 and upper-2", policies)
+		}
+	})
 }
 
 func TestGenerateCloudAccessPolicyToken(t *testing.T) {
     t.Run("should generate tokens and add it to the policy", func(t *testing.T) {
         client := NewClient()
-        nameArg := "TestArg"
+        nameArg := "test-arg"
         count := 100
 
         accessPolicy := client.GenerateCloudAccessPolicy(nameArg)
@@ -1136,7 +1886,7 @@ func TestGenerateCloudAccessPolicyToken(t *testing.T) {
 
     t.Run("should generate token with the correct name", func(t *testing.T) {
         client := NewClient()
-        nameArg := "TestName"
+        nameArg := "test-name"
         accessPolicy := client.GenerateCloudAccessPolicy(nameArg)
 
         want := nameArg
@@ -1149,7 +1899,7 @@ func TestGenerateCloudAccessPolicyToken(t *testing.T) {
 
     t.Run("should generate policy and return it", func(t *testing.T) {
         client := NewClient()
-        nameArg := "TestName"
+        nameArg := "test-name"
         accessPolicy := client.GenerateCloudAccessPolicy(nameArg)
 
         want := "*gapi.CloudAccessPolicyToken"
@@ -1161,10 +1911,42 @@ func TestGenerateCloudAccessPolicyToken(t *testing.T) {
     })
 }
 
+func TestGeneratedCloudAccessPolicyTokenSecrets(t *testing.T) {
+	t.Run("generated token should authenticate", func(t *testing.T) {
+		client := NewClient()
+		accessPolicy := client.GenerateCloudAccessPolicy("test-name")
+
+		token := client.GenerateCloudAccessPolicyToken("test-token", accessPolicy.ID)
+
+		if !strings.HasPrefix(token.Token, "glc_") {
+			t.Fatalf("expected a glc_ secret but got %q", token.Token)
+		}
+		identity, err := client.LookupToken(token.Token)
+		if err != nil || identity.AccessPolicyToken.ID != token.ID {
+			t.Errorf("expected the secret to resolve to the token but got %+v, %v", identity, err)
+		}
+		if client.CloudAccessPolicyTokenItems[0].Token != "" {
+			t.Errorf("expected the stored token to hold no secret")
+		}
+	})
+
+	t.Run("generated tokens should respect the quota", func(t *testing.T) {
+		client := NewClient()
+		client.Quotas.TokensPerAccessPolicy = 2
+		accessPolicy := client.GenerateCloudAccessPolicy("test-name")
+
+		tokens := client.GenerateCloudAccessPolicyTokens(3, "test-token", accessPolicy.ID)
+
+		if len(tokens) != 2 || len(client.CloudAccessPolicyTokenItems) != 2 {
+			t.Errorf("expected generation to stop at the quota but got %d tokens", len(client.CloudAccessPolicyTokenItems))
+		}
+	})
+}
+
 func TestGenerateCloudAccessPolicyTokens(t *testing.T) {
 	t.Run("should generate the specified number of tokens", func(t *testing.T) {
 		client := NewClient()
-		nameArg := "TestName"
+		nameArg := "test-name"
         accessPolicy := client.GenerateCloudAccessPolicy(nameArg)
 		count := 10
 
@@ -1177,4 +1959,26 @@ func TestGenerateCloudAccessPolicyTokens(t *testing.T) {
 			t.Errorf("got %v policies but want %v", got, want)
 		}
 	})
+
+	t.Run("should give each token a unique name within the policy", func(t *testing.T) {
+		client := NewClient()
+		accessPolicy := client.GenerateCloudAccessPolicy("test-name")
+
+		tokens := client.GenerateCloudAccessPolicyTokens(3, "dup", accessPolicy.ID)
+
+		if tokens[0].Name == tokens[1].Name || tokens[1].Name == tokens[2].Name || tokens[0].Name == tokens[2].Name {
+			t.Errorf("expected unique token names but got %q, %q and %q", tokens[0].Name, tokens[1].Name, tokens[2].Name)
+		}
+	})
+
+	t.Run("should slugify the prefix", func(t *testing.T) {
+		client := NewClient()
+		accessPolicy := client.GenerateCloudAccessPolicy("test-name")
+
+		tokens := client.GenerateCloudAccessPolicyTokens(2, "Test Token", accessPolicy.ID)
+
+		if len(tokens) != 2 || tokens[0].Name != "test-token-1" {
+			t.Errorf("got %v want tokens named after test-token", tokens)
+		}
+	})
 }
@@ -0,0 +1,166 @@
+package mockgrafana
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// QueryMetrics stands in for a stack's prometheus query endpoint. The username is the stack's prometheus instance id
+// and the password a cloud access policy token with the metrics:read scope and a realm covering the stack. The query
+// is a series selector such as up{env="dev"}, evaluated against SyntheticSeries and the series remote-written to the
+// stack. Like grafana cloud, series the realm's label policies don't allow are left out of the result rather than
+// failing the query. Handler serves it at /api/prom/api/v1/query and /api/prom/api/v1/series.
+func (client *MockClient) QueryMetrics(username, password, query string) ([]Series, error) {
+	stack, err := client.stackByInstanceID(username, func(stack *gapi.Stack) int { return stack.HmInstancePromID })
+	if err != nil {
+		return nil, err
+	}
+	labelPolicies, err := client.authorizeInstance(stack, password, "metrics:read")
+	if err != nil {
+		return nil, err
+	}
+	matchers, err := parseSeriesSelector(query)
+	if err != nil {
+		return nil, apiError(http.StatusBadRequest, err.Error())
+	}
+
+	var result []Series
+	for _, series := range append(append([]Series{}, client.SyntheticSeries...), client.receivedSeries[stack.ID]...) {
+		if MatchLabels(matchers, series.Labels) && matchLabelPolicies(labelPolicies, series.Labels) {
+			result = append(result, series)
+		}
+	}
+	return result, nil
+}
+
+// serveQuery serves an instant query like prometheus' /api/v1/query, with the latest sample of every series
+// QueryMetrics returns. Like prometheus, series without samples are left out; /api/v1/series still lists them.
+func (client *MockClient) serveQuery(w http.ResponseWriter, r *http.Request, username, password string) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return apiError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	series, err := client.QueryMetrics(username, password, r.FormValue("query"))
+	if err != nil {
+		writePromError(w, err)
+		return nil
+	}
+
+	type vectorSample struct {
+		Metric map[string]string `json:"metric"`
+		Value  [2]interface{}    `json:"value"`
+	}
+	result := make([]vectorSample, 0)
+	for _, s := range series {
+		if len(s.Samples) == 0 {
+			continue
+		}
+		latest := s.Samples[0]
+		for _, sample := range s.Samples {
+			if sample.Timestamp > latest.Timestamp {
+				latest = sample
+			}
+		}
+		result = append(result, vectorSample{
+			Metric: s.Labels,
+			Value:  [2]interface{}{float64(latest.Timestamp) / 1000, strconv.FormatFloat(latest.Value, 'f', -1, 64)},
+		})
+	}
+	writePromData(w, map[string]interface{}{"resultType": "vector", "result": result})
+	return nil
+}
+
+// serveSeries serves the label sets of the series matching any of the match[] selectors, like prometheus'
+// /api/v1/series
+func (client *MockClient) serveSeries(w http.ResponseWriter, r *http.Request, username, password string) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		return apiError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	if err := r.ParseForm(); err != nil {
+		writePromError(w, apiError(http.StatusBadRequest, err.Error()))
+		return nil
+	}
+	selectors := r.Form["match[]"]
+	if len(selectors) == 0 {
+		writePromError(w, apiError(http.StatusBadRequest, "no match[] parameter provided"))
+		return nil
+	}
+
+	result := make([]map[string]string, 0)
+	for _, selector := range selectors {
+		series, err := client.QueryMetrics(username, password, selector)
+		if err != nil {
+			writePromError(w, err)
+			return nil
+		}
+		for _, s := range series {
+			result = append(result, s.Labels)
+		}
+	}
+	writePromData(w, result)
+	return nil
+}
+
+// writePromData writes a successful prometheus api response
+func writePromData(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": data})
+}
+
+// writePromError writes the error in the shape prometheus api clients expect, keeping the status of an apiError
+func writePromError(w http.ResponseWriter, err error) {
+	status, errorType, message := http.StatusInternalServerError, "internal", err.Error()
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(statusErr.body, &body)
+		status, message = statusErr.status, body.Message
+		if status == http.StatusBadRequest {
+			errorType = "bad_data"
+		} else {
+			errorType = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": "error", "errorType": errorType, "error": message})
+}
+
+// parseSeriesSelector parses a selector with an optional metric name, such as up, up{env="dev"} or {env="dev"}
+func parseSeriesSelector(query string) ([]LabelMatcher, error) {
+	query = strings.TrimSpace(query)
+	name, rest := scanMetricName(query)
+
+	var matchers []LabelMatcher
+	if name != "" {
+		matchers = append(matchers, LabelMatcher{Name: "__name__", Type: MatchEqual, Value: name})
+	}
+	if rest = strings.TrimSpace(rest); name != "" && (rest == "" || rest == "{}") {
+		return matchers, nil
+	}
+	selectorMatchers, err := ParseLabelSelector(rest)
+	if err != nil {
+		return nil, err
+	}
+	return append(matchers, selectorMatchers...), nil
+}
+
+// scanMetricName is scanLabelName for metric names, which may also contain colons, as recording rules use
+func scanMetricName(s string) (string, string) {
+	end := 0
+	for end < len(s) {
+		c := s[end]
+		if c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (end > 0 && c >= '0' && c <= '9') {
+			end++
+			continue
+		}
+		break
+	}
+	return s[:end], s[end:]
+}
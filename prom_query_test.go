@@ -0,0 +1,173 @@
+package mockgrafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	synthetic := []Series{
+		{Labels: map[string]string{"__name__": "up", "env": "dev", "job": "api"}},
+		{Labels: map[string]string{"__name__": "up", "env": "prod", "job": "api"}},
+		{Labels: map[string]string{"__name__": "errors_total", "env": "dev", "job": "api"}},
+		{Labels: map[string]string{"__name__": "job:up:sum", "env": "dev"}},
+	}
+
+	t.Run("should return series matching the selector", func(t *testing.T) {
+		client := NewClient()
+		client.SyntheticSeries = synthetic
+		stack, token := newStackToken(t, client, []string{"metrics:read"})
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		for query, want := range map[string]int{`up`: 2, `up{env="prod"}`: 1, `{job="api"}`: 3, `{env=~"d.*"}`: 3, `job:up:sum{env="dev"}`: 1} {
+			got, err := client.QueryMetrics(username, token, query)
+			if err != nil {
+				t.Fatalf("query %s: expected no error but got %v", query, err)
+			}
+			if len(got) != want {
+				t.Errorf("query %s: got %d series want %d", query, len(got), want)
+			}
+		}
+	})
+
+	t.Run("should not return series outside the label policies", func(t *testing.T) {
+		client := NewClient()
+		client.SyntheticSeries = synthetic
+		stack, token := newStackToken(t, client, []string{"metrics:read"}, `{env="dev"}`)
+
+		got, err := client.QueryMetrics(fmt.Sprintf("%d", stack.HmInstancePromID), token, `up`)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if len(got) != 1 || got[0].Labels["env"] != "dev" {
+			t.Errorf("expected only dev series but got %v", got)
+		}
+	})
+
+	t.Run("should include remote-written series", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:read", "metrics:write"})
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+		client.RemoteWrite(username, token, []Series{{Labels: map[string]string{"__name__": "up", "env": "dev"}}})
+
+		got, _ := client.QueryMetrics(username, token, `up`)
+		if len(got) != 1 {
+			t.Errorf("got %v want the written series", got)
+		}
+	})
+
+	t.Run("should reject token without metrics:read and invalid queries", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		if _, err := client.QueryMetrics(username, token, `up`); err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+
+		client.CloudAccessPolicyItems[0].Scopes = []string{"metrics:read"}
+		if _, err := client.QueryMetrics(username, token, `up{env=}`); err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+}
+
+func TestServeQuery(t *testing.T) {
+	synthetic := []Series{
+		{Labels: map[string]string{"__name__": "up", "env": "dev"}, Samples: []Sample{{Timestamp: 1000, Value: 0}, {Timestamp: 2000, Value: 1}}},
+		{Labels: map[string]string{"__name__": "up", "env": "prod"}, Samples: []Sample{{Timestamp: 2000, Value: 1}}},
+		{Labels: map[string]string{"__name__": "up", "env": "test"}},
+	}
+
+	get := func(t *testing.T, url, username, password string, response interface{}) int {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		request.SetBasicAuth(username, password)
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			t.Fatalf("expected a json response but got %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	t.Run("should serve the latest sample of the series the label policies allow", func(t *testing.T) {
+		client := NewClient()
+		client.SyntheticSeries = synthetic
+		stack, token := newStackToken(t, client, []string{"metrics:read"}, `{env="dev"}`)
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+
+		var response struct {
+			Status string
+			Data   struct {
+				ResultType string
+				Result     []struct {
+					Metric map[string]string
+					Value  []interface{}
+				}
+			}
+		}
+		status := get(t, server.URL+"/api/prom/api/v1/query?query="+url.QueryEscape(`up`), fmt.Sprintf("%d", stack.HmInstancePromID), token, &response)
+
+		if status != http.StatusOK || response.Status != "success" || response.Data.ResultType != "vector" {
+			t.Fatalf("got status %d and response %+v", status, response)
+		}
+		result := response.Data.Result
+		if len(result) != 1 || result[0].Metric["env"] != "dev" || result[0].Value[0] != 2.0 || result[0].Value[1] != "1" {
+			t.Errorf("got result %+v want only the latest dev sample", result)
+		}
+	})
+
+	t.Run("should list series without samples", func(t *testing.T) {
+		client := NewClient()
+		client.SyntheticSeries = synthetic
+		stack, token := newStackToken(t, client, []string{"metrics:read"})
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+
+		var response struct {
+			Status string
+			Data   []map[string]string
+		}
+		query := url.Values{"match[]": {`up{env="prod"}`, `up{env="test"}`}}.Encode()
+		status := get(t, server.URL+"/api/prom/api/v1/series?"+query, fmt.Sprintf("%d", stack.HmInstancePromID), token, &response)
+
+		if status != http.StatusOK || len(response.Data) != 2 || response.Data[1]["env"] != "test" {
+			t.Errorf("got status %d and response %+v", status, response)
+		}
+	})
+
+	t.Run("should serve errors like prometheus", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		var response struct {
+			Status    string
+			ErrorType string
+			Error     string
+		}
+		status := get(t, server.URL+"/api/prom/api/v1/query?query=up", username, token, &response)
+		if status != http.StatusForbidden || response.Status != "error" || !strings.Contains(response.Error, "metrics:read") {
+			t.Errorf("got status %d and response %+v", status, response)
+		}
+
+		client.CloudAccessPolicyItems[0].Scopes = []string{"metrics:read"}
+		status = get(t, server.URL+"/api/prom/api/v1/query?query="+url.QueryEscape(`up{env=}`), username, token, &response)
+		if status != http.StatusBadRequest || response.ErrorType != "bad_data" {
+			t.Errorf("got status %d and response %+v", status, response)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package mockgrafana
+
+import (
+	"net/http"
+)
+
+// Quotas caps how many of each resource can be created. A limit of 0 means unlimited.
+type Quotas struct {
+	AccessPoliciesPerOrg       int
+	TokensPerAccessPolicy      int
+	ServiceAccountsPerInstance int
+}
+
+// QuotaUsage is how much of each quota is in use
+type QuotaUsage struct {
+	AccessPolicies        int
+	TokensPerAccessPolicy map[string]int
+	ServiceAccounts       int
+}
+
+// QuotaUsage returns the current usage of the quotas. Tokens are counted per access policy id.
+func (client *MockClient) QuotaUsage() QuotaUsage {
+	usage := QuotaUsage{
+		AccessPolicies:        len(client.CloudAccessPolicyItems),
+		TokensPerAccessPolicy: make(map[string]int),
+		ServiceAccounts:       len(client.ServiceAccountsDTO),
+	}
+	for _, token := range client.CloudAccessPolicyTokenItems {
+		usage.TokensPerAccessPolicy[token.AccessPolicyID]++
+	}
+	return usage
+}
+
+// checkQuota returns grafana's quota error when used has reached a non zero limit
+func checkQuota(limit, used int) error {
+	if limit > 0 && used >= limit {
+		return apiError(http.StatusForbidden, "Quota reached")
+	}
+	return nil
+}
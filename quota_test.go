@@ -0,0 +1,85 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestQuotas(t *testing.T) {
+	t.Run("should limit access policies per org", func(t *testing.T) {
+		client := NewClient()
+		client.Quotas.AccessPoliciesPerOrg = 2
+
+		var err error
+		for i := 0; i < 3; i++ {
+			_, err = client.CreateCloudAccessPolicy("us", gapi.CreateCloudAccessPolicyInput{
+				Name:   fmt.Sprintf("test-policy-%d", i),
+				Scopes: []string{"metrics:read"},
+			})
+		}
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 403") || !strings.Contains(err.Error(), "Quota reached") {
+			t.Errorf("expected a quota error but got %v", err)
+		}
+		if usage := client.QuotaUsage(); usage.AccessPolicies != 2 {
+			t.Errorf("got %d access policies want 2", usage.AccessPolicies)
+		}
+	})
+
+	t.Run("should limit tokens per access policy", func(t *testing.T) {
+		client := NewClient()
+		client.Quotas.TokensPerAccessPolicy = 1
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		other := client.GenerateCloudAccessPolicy("other-policy-name")
+
+		input := gapi.CreateCloudAccessPolicyTokenInput{AccessPolicyID: policy.ID, Name: "first"}
+		if _, err := client.CreateCloudAccessPolicyToken("us", input); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		input.Name = "second"
+		if _, err := client.CreateCloudAccessPolicyToken("us", input); err == nil || !strings.Contains(err.Error(), "Quota reached") {
+			t.Errorf("expected a quota error but got %v", err)
+		}
+		input.AccessPolicyID = other.ID
+		if _, err := client.CreateCloudAccessPolicyToken("us", input); err != nil {
+			t.Errorf("expected the quota to apply per policy but got %v", err)
+		}
+
+		if usage := client.QuotaUsage(); usage.TokensPerAccessPolicy[policy.ID] != 1 {
+			t.Errorf("got usage %+v", usage)
+		}
+	})
+
+	t.Run("should limit service accounts per instance", func(t *testing.T) {
+		client := NewClient()
+		client.Quotas.ServiceAccountsPerInstance = 1
+		client.GenerateStack("clabs")
+
+		if _, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "first"}); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if _, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "second"}); err == nil || !strings.Contains(err.Error(), "Quota reached") {
+			t.Errorf("expected a quota error but got %v", err)
+		}
+
+		if _, err := client.CreateGrafanaServiceAccountFromCloud("clabs", &gapi.CreateServiceAccountRequest{Name: "first"}); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		if _, err := client.CreateGrafanaServiceAccountFromCloud("clabs", &gapi.CreateServiceAccountRequest{Name: "second"}); err == nil || !strings.Contains(err.Error(), "Quota reached") {
+			t.Errorf("expected the stack instance to have the same quota but got %v", err)
+		}
+	})
+
+	t.Run("should not limit anything by default", func(t *testing.T) {
+		client := NewClient()
+
+		for i := 0; i < 10; i++ {
+			if _, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: fmt.Sprintf("sa-%d", i)}); err != nil {
+				t.Fatalf("expected no error but got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// Series is a prometheus time series as sent in a remote-write request
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Sample is a single value of a series, with its timestamp in milliseconds
+type Sample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// RemoteWrite stands in for a stack's prometheus remote-write endpoint. The username is the stack's prometheus
+// instance id and the password a cloud access policy token, as grafana agents send them. The token needs the
+// metrics:write scope and a realm covering the stack, and every series must match one of the realm's label
+// policies when it has any. Accepted series are kept for ReceivedSeries. Handler serves it at /api/prom/push.
+func (client *MockClient) RemoteWrite(username, password string, series []Series) error {
+	stack, err := client.stackByInstanceID(username, func(stack *gapi.Stack) int { return stack.HmInstancePromID })
+	if err != nil {
+		return err
+	}
+	labelPolicies, err := client.authorizeInstance(stack, password, "metrics:write")
+	if err != nil {
+		return err
+	}
+	for _, s := range series {
+		if !matchLabelPolicies(labelPolicies, s.Labels) {
+			return apiError(http.StatusForbidden, fmt.Sprintf("series %v is not allowed by the access policy's label policies", s.Labels))
+		}
+	}
+
+	if client.receivedSeries == nil {
+		client.receivedSeries = make(map[int64][]Series)
+	}
+	client.receivedSeries[stack.ID] = append(client.receivedSeries[stack.ID], series...)
+	return nil
+}
+
+// serveRemoteWrite decodes a snappy compressed protobuf remote-write request, as prometheus and grafana agents send
+// them, and passes its series to RemoteWrite
+func (client *MockClient) serveRemoteWrite(w http.ResponseWriter, r *http.Request, username, password string) error {
+	if err := requireMethod(r, http.MethodPost); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apiError(http.StatusBadRequest, err.Error())
+	}
+	body, err = snappyDecode(body)
+	if err != nil {
+		return apiError(http.StatusBadRequest, fmt.Sprintf("invalid snappy body: %s", err))
+	}
+	series, err := decodeWriteRequest(body)
+	if err != nil {
+		return apiError(http.StatusBadRequest, fmt.Sprintf("invalid write request: %s", err))
+	}
+	if err := client.RemoteWrite(username, password, series); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// ReceivedSeries returns the series remote-written to the stack with the given slug
+func (client *MockClient) ReceivedSeries(stackSlug string) []Series {
+	stack := client.stackBySlug(stackSlug)
+	if stack == nil {
+		return nil
+	}
+	return client.receivedSeries[stack.ID]
+}
+
+// stackByInstanceID returns the active stack whose hosted instance, as picked by instanceID, has the given id
+func (client *MockClient) stackByInstanceID(id string, instanceID func(*gapi.Stack) int) (*gapi.Stack, error) {
+	client.refreshStacks()
+	for _, stack := range client.StackItems {
+		if stack.Status != StackStatusDeleted && strconv.Itoa(instanceID(stack)) == id {
+			if err := checkStackActive(stack); err != nil {
+				return nil, err
+			}
+			return stack, nil
+		}
+	}
+	return nil, apiError(http.StatusUnauthorized, fmt.Sprintf("invalid instance id %q", id))
+}
+
+// authorizeInstance checks that the token is an access policy token with the scope and a realm covering the stack.
+// It returns the matchers of the label policies that restrict the token's access, or nil when access is unrestricted.
+func (client *MockClient) authorizeInstance(stack *gapi.Stack, token, scope string) ([][]LabelMatcher, error) {
+	identity, err := client.LookupToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if identity.AccessPolicy == nil {
+		return nil, apiError(http.StatusUnauthorized, "not an access policy token")
+	}
+
+	var hasScope bool
+	for _, s := range identity.Scopes {
+		if s == scope {
+			hasScope = true
+		}
+	}
+	if !hasScope {
+		return nil, apiError(http.StatusForbidden, fmt.Sprintf("access policy is missing the %s scope", scope))
+	}
+
+	var covered bool
+	var labelPolicies [][]LabelMatcher
+	for _, realm := range identity.AccessPolicy.Realms {
+		switch {
+		case realm.Type == "org" && realm.Identifier == strconv.FormatInt(stack.OrgID, 10):
+		case realm.Type == "stack" && realm.Identifier == strconv.FormatInt(stack.ID, 10):
+		default:
+			continue
+		}
+		if len(realm.LabelPolicies) == 0 {
+			return nil, nil
+		}
+		covered = true
+		for _, labelPolicy := range realm.LabelPolicies {
+			matchers, err := ParseLabelSelector(labelPolicy.Selector)
+			if err != nil {
+				return nil, apiError(http.StatusForbidden, err.Error())
+			}
+			labelPolicies = append(labelPolicies, matchers)
+		}
+	}
+	if !covered {
+		return nil, apiError(http.StatusForbidden, fmt.Sprintf("access policy has no realm for stack %q", stack.Slug))
+	}
+	return labelPolicies, nil
+}
+
+// matchLabelPolicies reports whether the labels satisfy any of the label policies, always matching when there are none
+func matchLabelPolicies(labelPolicies [][]LabelMatcher, labels map[string]string) bool {
+	if labelPolicies == nil {
+		return true
+	}
+	for _, matchers := range labelPolicies {
+		if MatchLabels(matchers, labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeWriteRequest decodes the series of a prometheus remote-write WriteRequest protobuf message, skipping its
+// metadata, exemplars and histograms
+func decodeWriteRequest(b []byte) ([]Series, error) {
+	var series []Series
+	err := decodeMessage(b, func(field int, wireType int, value []byte, number uint64) error {
+		if field != 1 || wireType != protoBytes {
+			return nil
+		}
+		s := Series{Labels: make(map[string]string)}
+		err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+			switch {
+			case field == 1 && wireType == protoBytes:
+				var name, labelValue string
+				err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+					switch {
+					case field == 1 && wireType == protoBytes:
+						name = string(value)
+					case field == 2 && wireType == protoBytes:
+						labelValue = string(value)
+					}
+					return nil
+				})
+				s.Labels[name] = labelValue
+				return err
+			case field == 2 && wireType == protoBytes:
+				var sample Sample
+				err := decodeMessage(value, func(field int, wireType int, value []byte, number uint64) error {
+					switch {
+					case field == 1 && wireType == protoFixed64:
+						sample.Value = math.Float64frombits(number)
+					case field == 2 && wireType == protoVarint:
+						sample.Timestamp = int64(number)
+					}
+					return nil
+				})
+				s.Samples = append(s.Samples, sample)
+				return err
+			}
+			return nil
+		})
+		series = append(series, s)
+		return err
+	})
+	return series, err
+}
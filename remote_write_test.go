@@ -0,0 +1,214 @@
+package mockgrafana
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// newStackToken creates a stack and an access policy token for it with the scopes and label policy selectors given
+func newStackToken(t *testing.T, client *MockClient, scopes []string, selectors ...string) (*gapi.Stack, string) {
+	t.Helper()
+	stack, err := client.GenerateStack("clabs")
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	policy, err := client.CreateCloudAccessPolicy("us", gapi.CreateCloudAccessPolicyInput{
+		Name:   "test-policy-name",
+		Scopes: scopes,
+		Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", fmt.Sprintf("%d", stack.ID), selectors...)},
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	token, err := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+		AccessPolicyID: policy.ID,
+		Name:           "test-token-name",
+	})
+	if err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	return stack, token.Token
+}
+
+func TestRemoteWrite(t *testing.T) {
+	series := []Series{{
+		Labels:  map[string]string{"__name__": "up", "env": "dev"},
+		Samples: []Sample{{Timestamp: 1000, Value: 1}},
+	}}
+
+	t.Run("should accept series with a metrics:write token for the stack", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+
+		if err := client.RemoteWrite(fmt.Sprintf("%d", stack.HmInstancePromID), token, series); err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+
+		if got := client.ReceivedSeries("clabs"); len(got) != 1 || got[0].Labels["env"] != "dev" {
+			t.Errorf("got received series %v", got)
+		}
+	})
+
+	t.Run("should reject wrong instance id and unknown token", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+
+		if err := client.RemoteWrite(fmt.Sprintf("%d", stack.HlInstanceID), token, series); err == nil || !strings.HasPrefix(err.Error(), "status: 401") {
+			t.Errorf("expected an unauthorized error but got %v", err)
+		}
+		if err := client.RemoteWrite(fmt.Sprintf("%d", stack.HmInstancePromID), "glc_invalid", series); err == nil || !strings.HasPrefix(err.Error(), "status: 401") {
+			t.Errorf("expected an unauthorized error but got %v", err)
+		}
+	})
+
+	t.Run("should reject token without metrics:write", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:read", "logs:write"})
+
+		err := client.RemoteWrite(fmt.Sprintf("%d", stack.HmInstancePromID), token, series)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+	})
+
+	t.Run("should reject token for another stack", func(t *testing.T) {
+		client := NewClient()
+		_, token := newStackToken(t, client, []string{"metrics:write"})
+		other, _ := client.GenerateStack("celo")
+
+		err := client.RemoteWrite(fmt.Sprintf("%d", other.HmInstancePromID), token, series)
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+	})
+
+	t.Run("should enforce label policies", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"}, `{env="dev"}`)
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		if err := client.RemoteWrite(username, token, series); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		prod := []Series{{Labels: map[string]string{"__name__": "up", "env": "prod"}}}
+		if err := client.RemoteWrite(username, token, prod); err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+		if got := client.ReceivedSeries("clabs"); len(got) != 1 {
+			t.Errorf("expected only the allowed series to be stored but got %v", got)
+		}
+	})
+
+	t.Run("should enforce label policies on org realms", func(t *testing.T) {
+		client := NewClient()
+		stack, _ := client.GenerateStack("clabs")
+		policy, _ := client.CreateCloudAccessPolicy("us", gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:write"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("org", fmt.Sprintf("%d", stack.OrgID), `{env="dev"}`)},
+		})
+		token, _ := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "test-token-name",
+		})
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		if err := client.RemoteWrite(username, token.Token, series); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+		prod := []Series{{Labels: map[string]string{"__name__": "up", "env": "prod"}}}
+		if err := client.RemoteWrite(username, token.Token, prod); err == nil || !strings.HasPrefix(err.Error(), "status: 403") {
+			t.Errorf("expected a forbidden error but got %v", err)
+		}
+	})
+}
+
+// encodeWriteRequest encodes the series as a snappy compressed remote-write WriteRequest
+func encodeWriteRequest(series []Series) []byte {
+	var request []byte
+	for _, s := range series {
+		var timeSeries []byte
+		for name, value := range s.Labels {
+			label := appendProtoField(nil, 1, []byte(name))
+			label = appendProtoField(label, 2, []byte(value))
+			timeSeries = appendProtoField(timeSeries, 1, label)
+		}
+		for _, sample := range s.Samples {
+			encoded := binary.AppendUvarint(nil, 1<<3|protoFixed64)
+			encoded = binary.LittleEndian.AppendUint64(encoded, math.Float64bits(sample.Value))
+			encoded = binary.AppendUvarint(encoded, 2<<3|protoVarint)
+			encoded = binary.AppendUvarint(encoded, uint64(sample.Timestamp))
+			timeSeries = appendProtoField(timeSeries, 2, encoded)
+		}
+		request = appendProtoField(request, 1, timeSeries)
+	}
+	return snappyLiteral(request)
+}
+
+func TestServeRemoteWrite(t *testing.T) {
+	series := []Series{{
+		Labels:  map[string]string{"__name__": "up", "env": "dev"},
+		Samples: []Sample{{Timestamp: 1000, Value: 1.5}},
+	}}
+
+	push := func(t *testing.T, url, username, password string, body []byte) int {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPost, url+"/api/prom/push", bytes.NewReader(body))
+		request.Header.Set("Content-Encoding", "snappy")
+		request.Header.Set("Content-Type", "application/x-protobuf")
+		request.SetBasicAuth(username, password)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("expected no error but got %v", err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	t.Run("should accept remote-write requests over http", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"})
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+
+		if got := push(t, server.URL, fmt.Sprintf("%d", stack.HmInstancePromID), token, encodeWriteRequest(series)); got != http.StatusOK {
+			t.Fatalf("got status %d want %d", got, http.StatusOK)
+		}
+
+		got := client.ReceivedSeries("clabs")
+		if len(got) != 1 || got[0].Labels["env"] != "dev" || len(got[0].Samples) != 1 || got[0].Samples[0] != series[0].Samples[0] {
+			t.Errorf("got received series %v", got)
+		}
+	})
+
+	t.Run("should serve the errors of RemoteWrite", func(t *testing.T) {
+		client := NewClient()
+		stack, token := newStackToken(t, client, []string{"metrics:write"}, `{env="prod"}`)
+		server := httptest.NewServer(client.Handler())
+		defer server.Close()
+		username := fmt.Sprintf("%d", stack.HmInstancePromID)
+
+		if got := push(t, server.URL, username, token, encodeWriteRequest(series)); got != http.StatusForbidden {
+			t.Errorf("got status %d want %d for a series outside the label policies", got, http.StatusForbidden)
+		}
+		if got := push(t, server.URL, username, "glc_unknown", encodeWriteRequest(series)); got != http.StatusUnauthorized {
+			t.Errorf("got status %d want %d for an unknown token", got, http.StatusUnauthorized)
+		}
+		if got := push(t, server.URL, username, token, []byte("not snappy")); got != http.StatusBadRequest {
+			t.Errorf("got status %d want %d for a corrupt body", got, http.StatusBadRequest)
+		}
+		if got := client.ReceivedSeries("clabs"); len(got) != 0 {
+			t.Errorf("expected no series to be stored but got %v", got)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// CloudAPIKeyRoles are the roles grafana cloud accepts for cloud api keys
+var CloudAPIKeyRoles = []string{"Viewer", "Editor", "Admin", "MetricsPublisher", "PluginPublisher"}
+
+// InstanceRoles are the roles a grafana instance accepts for service accounts and legacy api keys
+var InstanceRoles = []string{"Viewer", "Editor", "Admin", "None"}
+
+// validateRole returns grafana's bad request error when role isn't one of roles
+func validateRole(role string, roles []string) error {
+	for _, r := range roles {
+		if role == r {
+			return nil
+		}
+	}
+	return apiError(http.StatusBadRequest, fmt.Sprintf("invalid role %q, must be one of %v", role, roles))
+}
+
+// CloudAPIKeyRoleGenerator returns a random role from CloudAPIKeyRoles
+func CloudAPIKeyRoleGenerator() string {
+	rand.Seed(time.Now().UnixNano())
+	return CloudAPIKeyRoles[rand.Intn(len(CloudAPIKeyRoles))]
+}
@@ -0,0 +1,80 @@
+package mockgrafana
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestCloudAPIKeyRoles(t *testing.T) {
+	t.Run("should accept cloud roles and reject others", func(t *testing.T) {
+		client := NewClient()
+
+		for _, role := range CloudAPIKeyRoles {
+			if _, err := client.CreateCloudAPIKey("", &gapi.CreateCloudAPIKeyInput{Name: role, Role: role}); err != nil {
+				t.Errorf("role %q: expected no error but got %v", role, err)
+			}
+		}
+		for _, role := range []string{"", "None", "admin", "Owner"} {
+			_, err := client.CreateCloudAPIKey("", &gapi.CreateCloudAPIKeyInput{Name: "key-" + role, Role: role})
+			if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+				t.Errorf("role %q: expected a bad request error but got %v", role, err)
+			}
+		}
+	})
+
+	t.Run("should generate cloud roles", func(t *testing.T) {
+		client := NewClient()
+
+		keys, err := client.GenerateCloudAPIKeys(20, "test", "")
+		if err != nil || len(keys) != 20 {
+			t.Errorf("got %d keys, %v", len(keys), err)
+		}
+	})
+}
+
+func TestInstanceRoles(t *testing.T) {
+	t.Run("should reject cloud only roles for service accounts", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "publisher", Role: "MetricsPublisher"})
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+		if _, err := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "none", Role: "None"}); err != nil {
+			t.Errorf("expected no error but got %v", err)
+		}
+	})
+
+	t.Run("should not update service account to an invalid role", func(t *testing.T) {
+		client := NewClient()
+		sa, _ := client.CreateServiceAccount(gapi.CreateServiceAccountRequest{Name: "deploy", Role: "Viewer"})
+
+		_, err := client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{Name: "renamed", Role: "PluginPublisher"})
+
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+		if client.ServiceAccountsDTO[0].Name != "deploy" || client.ServiceAccountsDTO[0].Role != "Viewer" {
+			t.Errorf("expected service account to be unchanged but got %+v", client.ServiceAccountsDTO[0])
+		}
+	})
+
+	t.Run("should reject cloud only roles for legacy api keys", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.CreateAPIKey(gapi.CreateAPIKeyRequest{Name: "publisher", Role: "MetricsPublisher"})
+		if err == nil || !strings.HasPrefix(err.Error(), "status: 400") {
+			t.Errorf("expected a bad request error but got %v", err)
+		}
+	})
+
+	t.Run("should generate instance roles", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			if err := validateRole(RoleGenerator(), InstanceRoles); err != nil {
+				t.Errorf("expected generated role to be valid but got %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package mockgrafana
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CloudAccessPolicyScopes is the catalogue of scopes grafana cloud accepts on an access policy
+var CloudAccessPolicyScopes = []string{
+	"metrics:read",
+	"metrics:write",
+	"metrics:import",
+	"logs:read",
+	"logs:write",
+	"traces:read",
+	"traces:write",
+	"profiles:read",
+	"profiles:write",
+	"alerts:read",
+	"alerts:write",
+	"rules:read",
+	"rules:write",
+	"accesspolicies:read",
+	"accesspolicies:write",
+	"accesspolicies:delete",
+	"stacks:read",
+	"stacks:write",
+	"stacks:delete",
+	"stack-service-accounts:write",
+	"stack-plugins:read",
+	"stack-plugins:write",
+	"stack-plugins:delete",
+	"orgs:read",
+	"billing-metrics:read",
+}
+
+// IsValidScope reports whether the scope is part of the grafana cloud catalogue
+func IsValidScope(scope string) bool {
+	for _, valid := range CloudAccessPolicyScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// validateScopes rejects empty or unknown scopes when the client is in strict mode
+func (client *MockClient) validateScopes(scopes []string) error {
+	if !client.Strict {
+		return nil
+	}
+	if len(scopes) == 0 {
+		return apiError(http.StatusBadRequest, "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !IsValidScope(scope) {
+			return apiError(http.StatusBadRequest, fmt.Sprintf("invalid scope %q", scope))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package mockgrafana
+
+import (
+	"testing"
+)
+
+func TestIsValidScope(t *testing.T) {
+	cases := map[string]bool{
+		"metrics:read":         true,
+		"logs:write":           true,
+		"accesspolicies:write": true,
+		"stacks:delete":        true,
+		"testScope":            false,
+		"metrics:admin":        false,
+		"":                     false,
+	}
+	for scope, want := range cases {
+		if got := IsValidScope(scope); got != want {
+			t.Errorf("scope %q: got %v want %v", scope, got, want)
+		}
+	}
+}
+
+func TestScopeGenerator(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if scope := ScopeGenerator(); !IsValidScope(scope) {
+			t.Errorf("generated scope %q is not in the catalogue", scope)
+		}
+	}
+}
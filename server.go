@@ -0,0 +1,56 @@
+package mockgrafana
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Handler serves the stack endpoints that grafana agents and log shippers talk to, so they can be pointed at the
+// mock, for instance through httptest.NewServer. Like grafana cloud, requests authenticate with basic auth: the
+// username is the stack's instance id and the password a cloud access policy token. Requests are handled one at a
+// time, but the client shouldn't be changed directly while the handler is serving.
+func (client *MockClient) Handler() http.Handler {
+	var mu sync.Mutex
+	handle := func(serve func(w http.ResponseWriter, r *http.Request, username, password string) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				writeError(w, apiError(http.StatusUnauthorized, "basic auth required"))
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err := serve(w, r, username, password); err != nil {
+				writeError(w, err)
+			}
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/prom/push", handle(client.serveRemoteWrite))
+	mux.Handle("/loki/api/v1/push", handle(client.serveLokiPush))
+	mux.Handle("/api/prom/api/v1/query", handle(client.serveQuery))
+	mux.Handle("/api/prom/api/v1/series", handle(client.serveSeries))
+	return mux
+}
+
+// writeError writes the status and body of an apiError, and a 500 for any other error
+func writeError(w http.ResponseWriter, err error) {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusErr.status)
+	w.Write(statusErr.body)
+}
+
+// requireMethod returns grafana's method not allowed error unless the request uses the given method
+func requireMethod(r *http.Request, method string) error {
+	if r.Method != method {
+		return apiError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package mockgrafana
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("should require basic auth", func(t *testing.T) {
+		client := NewClient()
+		recorder := httptest.NewRecorder()
+
+		client.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/prom/push", nil))
+
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("got status %d want %d", recorder.Code, http.StatusUnauthorized)
+		}
+		if !strings.Contains(recorder.Body.String(), `"message":"basic auth required"`) {
+			t.Errorf("got body %q", recorder.Body.String())
+		}
+	})
+
+	t.Run("should reject other methods on push endpoints", func(t *testing.T) {
+		client := NewClient()
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/prom/push", nil)
+		request.SetBasicAuth("1", "token")
+
+		client.Handler().ServeHTTP(recorder, request)
+
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Errorf("got status %d want %d", recorder.Code, http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package mockgrafana
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+// Token types reported by DecodeToken
+const (
+	TokenTypeServiceAccount = "service-account"
+	TokenTypeCloud          = "cloud"
+	TokenTypeAPIKey         = "api-key"
+)
+
+const (
+	serviceAccountTokenPrefix = "glsa_"
+	cloudTokenPrefix          = "glc_"
+	secretLength              = 32
+	secretCharacters          = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// DecodedToken holds the information grafana embeds in a token secret
+type DecodedToken struct {
+	Type   string
+	Name   string
+	OrgID  string
+	Region string
+	Secret string
+}
+
+// cloudTokenPayload is the json encoded after the glc_ prefix of a grafana cloud token
+type cloudTokenPayload struct {
+	OrgID  string `json:"o"`
+	Name   string `json:"n"`
+	Secret string `json:"k"`
+	Meta   struct {
+		Region string `json:"r"`
+	} `json:"m"`
+}
+
+// apiKeyPayload is the json a legacy grafana api key is the base64 encoding of
+type apiKeyPayload struct {
+	Secret string `json:"k"`
+	Name   string `json:"n"`
+	OrgID  int64  `json:"id"`
+}
+
+// hashSecret returns the hash the mock stores in place of a secret
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomSecret() string {
+	secret := make([]byte, secretLength)
+	for i := range secret {
+		secret[i] = secretCharacters[rand.Intn(len(secretCharacters))]
+	}
+	return string(secret)
+}
+
+func serviceAccountTokenChecksum(secret string) string {
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.ChecksumIEEE([]byte(serviceAccountTokenPrefix+secret)))
+	return hex.EncodeToString(checksum)
+}
+
+// newServiceAccountToken returns a glsa_ token with a valid checksum, like grafana issues for service accounts
+func newServiceAccountToken() string {
+	secret := randomSecret()
+	return serviceAccountTokenPrefix + secret + "_" + serviceAccountTokenChecksum(secret)
+}
+
+// newCloudToken returns a glc_ token embedding the org, name and region, like grafana cloud issues
+func newCloudToken(orgID, name, region string) string {
+	payload := cloudTokenPayload{
+		OrgID:  orgID,
+		Name:   name,
+		Secret: randomSecret(),
+	}
+	payload.Meta.Region = region
+	data, _ := json.Marshal(payload)
+	return cloudTokenPrefix + base64.StdEncoding.EncodeToString(data)
+}
+
+// newAPIKey returns a legacy grafana api key for the named key
+func newAPIKey(name string, orgID int64) string {
+	data, _ := json.Marshal(apiKeyPayload{
+		Secret: randomSecret(),
+		Name:   name,
+		OrgID:  orgID,
+	})
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// DecodeToken parses a glsa_ service account token, glc_ grafana cloud token or legacy api key and returns the
+// information embedded in it. Service account token checksums are verified.
+func DecodeToken(token string) (*DecodedToken, error) {
+	switch {
+	case strings.HasPrefix(token, serviceAccountTokenPrefix):
+		parts := strings.Split(strings.TrimPrefix(token, serviceAccountTokenPrefix), "_")
+		if len(parts) != 2 || len(parts[0]) != secretLength {
+			return nil, fmt.Errorf("malformed service account token")
+		}
+		if serviceAccountTokenChecksum(parts[0]) != parts[1] {
+			return nil, fmt.Errorf("invalid service account token checksum")
+		}
+		return &DecodedToken{
+			Type:   TokenTypeServiceAccount,
+			Secret: parts[0],
+		}, nil
+
+	case strings.HasPrefix(token, cloudTokenPrefix):
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, cloudTokenPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("malformed cloud token: %v", err)
+		}
+		var payload cloudTokenPayload
+		if err := json.Unmarshal(data, &payload); err != nil || payload.Secret == "" {
+			return nil, fmt.Errorf("malformed cloud token payload")
+		}
+		return &DecodedToken{
+			Type:   TokenTypeCloud,
+			Name:   payload.Name,
+			OrgID:  payload.OrgID,
+			Region: payload.Meta.Region,
+			Secret: payload.Secret,
+		}, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("unrecognised token format")
+	}
+	var payload apiKeyPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Secret == "" {
+		return nil, fmt.Errorf("unrecognised token format")
+	}
+	return &DecodedToken{
+		Type:   TokenTypeAPIKey,
+		Name:   payload.Name,
+		OrgID:  fmt.Sprintf("%d", payload.OrgID),
+		Secret: payload.Secret,
+	}, nil
+}
+
+// TokenIdentity describes what a token secret belongs to. Only the fields for the kind of credential that
+// matched are set.
+type TokenIdentity struct {
+	Name      string
+	Role      string
+	Scopes    []string
+	ExpiresAt *time.Time
+
+	ServiceAccount    *gapi.ServiceAccountDTO
+	ServiceAccountKey *Token
+	APIKey            *APIKey
+	CloudAPIKey       *gapi.CloudAPIKey
+	AccessPolicy      *gapi.CloudAccessPolicy
+	AccessPolicyToken *gapi.CloudAccessPolicyToken
+}
+
+// LookupToken resolves a token secret back to the service account, legacy api key, cloud api key or access policy
+// it belongs to. Unknown, expired and disabled credentials are rejected with grafana's unauthorized error, and a
+// successful lookup counts as use of the token.
+func (client *MockClient) LookupToken(secret string) (*TokenIdentity, error) {
+	identity := client.findToken(secret)
+	if identity == nil {
+		return nil, apiError(http.StatusUnauthorized, "invalid API key")
+	}
+	if err := client.checkToken(identity); err != nil {
+		return nil, err
+	}
+	client.recordTokenUse(identity)
+	return identity, nil
+}
+
+func (client *MockClient) findToken(secret string) *TokenIdentity {
+	if secret == "" {
+		return nil
+	}
+	hash := hashSecret(secret)
+
+	for idx := range client.Tokens {
+		token := &client.Tokens[idx]
+		if token.HashedKey != hash {
+			continue
+		}
+		identity := &TokenIdentity{
+			Name:              token.Name,
+			ExpiresAt:         token.Expiration,
+			ServiceAccountKey: token,
+		}
+		for saIdx := range client.ServiceAccountsDTO {
+			if client.ServiceAccountsDTO[saIdx].ID == token.ServiceAccountID {
+				identity.ServiceAccount = &client.ServiceAccountsDTO[saIdx]
+				identity.Role = identity.ServiceAccount.Role
+			}
+		}
+		return identity
+	}
+
+	for idx := range client.APIKeys {
+		key := &client.APIKeys[idx]
+		if key.HashedKey == hash {
+			return &TokenIdentity{
+				Name:      key.Name,
+				Role:      key.Role,
+				ExpiresAt: key.Expiration,
+				APIKey:    key,
+			}
+		}
+	}
+
+	for _, key := range client.CloudAPIKeys {
+		if client.cloudAPIKeyHashes[key] == hash {
+			return &TokenIdentity{
+				Name:        key.Name,
+				Role:        key.Role,
+				CloudAPIKey: key,
+			}
+		}
+	}
+
+	for _, token := range client.CloudAccessPolicyTokenItems {
+		if client.accessPolicyTokenHashes[token] != hash {
+			continue
+		}
+		identity := &TokenIdentity{
+			Name:              token.Name,
+			ExpiresAt:         token.ExpiresAt,
+			AccessPolicyToken: token,
+		}
+		for _, policy := range client.CloudAccessPolicyItems {
+			if policy.ID == token.AccessPolicyID {
+				identity.AccessPolicy = policy
+				identity.Scopes = policy.Scopes
+			}
+		}
+		return identity
+	}
+	return nil
+}
+
+// checkToken rejects identities grafana would refuse to authenticate
+func (client *MockClient) checkToken(identity *TokenIdentity) error {
+	if identity.ExpiresAt != nil && identity.ExpiresAt.Before(client.now()) {
+		return apiError(http.StatusUnauthorized, "API key expired")
+	}
+	if identity.ServiceAccount != nil && identity.ServiceAccount.IsDisabled {
+		return apiError(http.StatusUnauthorized, "service account is disabled")
+	}
+	return nil
+}
+
+// recordTokenUse stamps the last used time of service account and access policy tokens, and the first used time
+// of access policy tokens, with the mock's clock
+func (client *MockClient) recordTokenUse(identity *TokenIdentity) {
+	now := client.now()
+	if identity.ServiceAccountKey != nil {
+		identity.ServiceAccountKey.LastUsedAt = &now
+	}
+	if identity.AccessPolicyToken != nil {
+		if identity.AccessPolicyToken.FirstUsedAt.IsZero() {
+			identity.AccessPolicyToken.FirstUsedAt = now
+		}
+		if client.accessPolicyTokenLastUsed == nil {
+			client.accessPolicyTokenLastUsed = make(map[*gapi.CloudAccessPolicyToken]time.Time)
+		}
+		client.accessPolicyTokenLastUsed[identity.AccessPolicyToken] = now
+	}
+}
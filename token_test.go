@@ -0,0 +1,270 @@
+package mockgrafana
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-api-golang-client"
+)
+
+func TestDecodeToken(t *testing.T) {
+	t.Run("should decode service account tokens", func(t *testing.T) {
+		client := NewClient()
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		if !regexp.MustCompile(`^glsa_[a-zA-Z0-9]{32}_[0-9a-f]{8}$`).MatchString(token.Key) {
+			t.Errorf("got unexpected service account token format %q", token.Key)
+		}
+
+		decoded, err := DecodeToken(token.Key)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if decoded.Type != TokenTypeServiceAccount || len(decoded.Secret) != 32 {
+			t.Errorf("got unexpected decoded token %+v", decoded)
+		}
+	})
+
+	t.Run("should reject service account tokens with a bad checksum", func(t *testing.T) {
+		client := NewClient()
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		tampered := token.Key[:len(token.Key)-8] + "00000000"
+		if tampered == token.Key {
+			tampered = token.Key[:len(token.Key)-8] + "ffffffff"
+		}
+
+		if _, err := DecodeToken(tampered); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should decode cloud api keys", func(t *testing.T) {
+		client := NewClient()
+		client.OrgID = 42
+		key, _ := client.GenerateCloudAPIKey("test-key", "")
+
+		decoded, err := DecodeToken(key.Token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if decoded.Type != TokenTypeCloud || decoded.Name != "test-key" || decoded.OrgID != "42" {
+			t.Errorf("got unexpected decoded token %+v", decoded)
+		}
+	})
+
+	t.Run("should decode legacy api keys", func(t *testing.T) {
+		client := NewClient()
+		key, _ := client.CreateAPIKey(gapi.CreateAPIKeyRequest{Name: "legacy", Role: "Viewer"})
+
+		decoded, err := DecodeToken(key.Key)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if decoded.Type != TokenTypeAPIKey || decoded.Name != "legacy" || decoded.OrgID != "1" {
+			t.Errorf("got unexpected decoded token %+v", decoded)
+		}
+	})
+
+	t.Run("should reject unknown formats", func(t *testing.T) {
+		for _, token := range []string{"", "MockToken", "glc_not-base64!", "glsa_short_00000000"} {
+			if _, err := DecodeToken(token); err == nil {
+				t.Errorf("expected an error for %q, got none", token)
+			}
+		}
+	})
+}
+
+func TestLookupToken(t *testing.T) {
+	t.Run("should return error for unknown secret", func(t *testing.T) {
+		client := NewClient()
+
+		_, err := client.LookupToken("glsa_unknown")
+
+		if err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should resolve service account token", func(t *testing.T) {
+		client := NewClient()
+		sa, _ := client.GenerateServiceAccount("deploy-bot", "Editor")
+		token, _ := client.GenerateServiceAccountToken("deploy-token", sa.ID)
+
+		identity, err := client.LookupToken(token.Key)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if identity.ServiceAccount == nil || identity.ServiceAccount.ID != sa.ID {
+			t.Errorf("expected service account %d, got %+v", sa.ID, identity.ServiceAccount)
+		}
+		if identity.Name != "deploy-token" || identity.Role != "Editor" {
+			t.Errorf("got unexpected identity %+v", identity)
+		}
+	})
+
+	t.Run("should reject expired service account token", func(t *testing.T) {
+		client := NewClient()
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.CreateServiceAccountToken(gapi.CreateServiceAccountTokenRequest{
+			Name:             "short-lived",
+			ServiceAccountID: sa.ID,
+			SecondsToLive:    60,
+		})
+
+		now = now.Add(2 * time.Minute)
+
+		if _, err := client.LookupToken(token.Key); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+		if err := client.Initialize(token.Key, ""); err == nil {
+			t.Errorf("expected Initialize to fail, got none")
+		}
+	})
+
+	t.Run("should reject token of disabled service account", func(t *testing.T) {
+		client := NewClient()
+		disabled := true
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+		client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{IsDisabled: &disabled})
+
+		if _, err := client.LookupToken(token.Key); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+
+	t.Run("should resolve access policy token with its scopes", func(t *testing.T) {
+		client := NewClient()
+		policy, _ := client.CreateCloudAccessPolicy("us", gapi.CreateCloudAccessPolicyInput{
+			Name:   "test-policy-name",
+			Scopes: []string{"metrics:read", "logs:write"},
+			Realms: []gapi.CloudAccessPolicyRealm{NewRealm("stack", "clabs")},
+		})
+		expiresAt := time.Now().Add(time.Hour)
+		token, _ := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "test-token-name",
+			ExpiresAt:      &expiresAt,
+		})
+
+		identity, err := client.LookupToken(token.Token)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if identity.AccessPolicy == nil || identity.AccessPolicy.ID != policy.ID {
+			t.Errorf("expected access policy %v, got %+v", policy.ID, identity.AccessPolicy)
+		}
+		if len(identity.Scopes) != 2 || identity.Scopes[1] != "logs:write" {
+			t.Errorf("got scopes %v", identity.Scopes)
+		}
+		if identity.ExpiresAt == nil || !identity.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("got expiry %v want %v", identity.ExpiresAt, expiresAt)
+		}
+	})
+
+	t.Run("should resolve cloud and legacy api keys", func(t *testing.T) {
+		client := NewClient()
+		cloudKey, _ := client.GenerateCloudAPIKey("cloud-key", "Admin")
+		apiKey, _ := client.GenerateAPIKey("legacy-key", "Viewer")
+
+		identity, err := client.LookupToken(cloudKey.Token)
+		if err != nil || identity.CloudAPIKey == nil || identity.Role != "Admin" {
+			t.Errorf("got identity %+v and error %v for cloud api key", identity, err)
+		}
+
+		identity, err = client.LookupToken(apiKey.Key)
+		if err != nil || identity.APIKey == nil || identity.Role != "Viewer" {
+			t.Errorf("got identity %+v and error %v for legacy api key", identity, err)
+		}
+	})
+}
+
+func TestTokenLastUsed(t *testing.T) {
+	t.Run("should record last use of service account token", func(t *testing.T) {
+		client := NewClient()
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		lastUsedAt, err := client.ServiceAccountTokenLastUsedAt(sa.ID, token.ID)
+		if err != nil || lastUsedAt != nil {
+			t.Fatalf("expected unused token, got last used %v, %v", lastUsedAt, err)
+		}
+
+		now = now.Add(time.Hour)
+		client.Initialize(token.Key, "")
+
+		got, _ := client.ServiceAccountTokenLastUsedAt(sa.ID, token.ID)
+		if got == nil || !got.Equal(now) {
+			t.Errorf("got last used %v want %v", got, now)
+		}
+	})
+
+	t.Run("should not find last use of unknown service account token", func(t *testing.T) {
+		client := NewClient()
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+
+		if _, err := client.ServiceAccountTokenLastUsedAt(sa.ID+1, token.ID); err == nil {
+			t.Errorf("expected error for unknown service account but got none")
+		}
+		if _, err := client.ServiceAccountTokenLastUsedAt(sa.ID, token.ID+1); err == nil {
+			t.Errorf("expected error for unknown token but got none")
+		}
+	})
+
+	t.Run("should not record use of rejected token", func(t *testing.T) {
+		client := NewClient()
+		disabled := true
+		sa, _ := client.GenerateServiceAccount("", "")
+		token, _ := client.GenerateServiceAccountToken("", sa.ID)
+		client.UpdateServiceAccount(sa.ID, gapi.UpdateServiceAccountRequest{IsDisabled: &disabled})
+
+		client.Initialize(token.Key, "")
+
+		if client.Tokens[0].LastUsedAt != nil {
+			t.Errorf("expected no last use, got %v", client.Tokens[0].LastUsedAt)
+		}
+	})
+
+	t.Run("should record first and last use of access policy token", func(t *testing.T) {
+		client := NewClient()
+		now := time.Now()
+		client.Now = func() time.Time { return now }
+		policy := client.GenerateCloudAccessPolicy("test-policy-name")
+		token, _ := client.CreateCloudAccessPolicyToken("us", gapi.CreateCloudAccessPolicyTokenInput{
+			AccessPolicyID: policy.ID,
+			Name:           "test-token-name",
+		})
+
+		lastUsedAt, _ := client.CloudAccessPolicyTokenLastUsedAt("us", token.ID)
+		if lastUsedAt != nil {
+			t.Fatalf("expected unused token, got last used %v", lastUsedAt)
+		}
+
+		firstUse := now.Add(time.Hour)
+		now = firstUse
+		client.Initialize(token.Token, "")
+		now = now.Add(time.Hour)
+		client.LookupToken(token.Token)
+
+		items, _ := client.CloudAccessPolicyTokens("us", policy.ID)
+		if !items.Items[0].FirstUsedAt.Equal(firstUse) {
+			t.Errorf("got first used %v want %v", items.Items[0].FirstUsedAt, firstUse)
+		}
+
+		lastUsedAt, _ = client.CloudAccessPolicyTokenLastUsedAt("us", token.ID)
+		if lastUsedAt == nil || !lastUsedAt.Equal(now) {
+			t.Errorf("got last used %v want %v", lastUsedAt, now)
+		}
+	})
+}
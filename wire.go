@@ -0,0 +1,133 @@
+package mockgrafana
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// decodeMessage calls field for every field of a protobuf message, with the payload of length delimited fields in
+// value and the number of the others in number
+func decodeMessage(b []byte, field func(field int, wireType int, value []byte, number uint64) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		b = b[n:]
+
+		var value []byte
+		var number uint64
+		wireType := int(key & 7)
+		switch wireType {
+		case protoVarint:
+			number, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("invalid varint")
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			number, b = binary.LittleEndian.Uint64(b), b[8:]
+		case protoBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return errors.New("truncated length delimited field")
+			}
+			value, b = b[n:n+int(length)], b[n+int(length):]
+		case protoFixed32:
+			if len(b) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			number, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wireType)
+		}
+		if err := field(int(key>>3), wireType, value, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snappyDecode decompresses a snappy block, the encoding prometheus remote-write bodies use
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > math.MaxInt32 {
+		return nil, errors.New("invalid length")
+	}
+	src = src[n:]
+
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		src = src[1:]
+
+		var size, offset int
+		switch tag & 3 {
+		case 0:
+			size = int(tag >> 2)
+			if size >= 60 {
+				extra := size - 59
+				if len(src) < extra {
+					return nil, errors.New("truncated literal length")
+				}
+				size = 0
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[i])
+				}
+				src = src[extra:]
+			}
+			size++
+			if size <= 0 || len(src) < size {
+				return nil, errors.New("truncated literal")
+			}
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+			continue
+		case 1:
+			if len(src) < 1 {
+				return nil, errors.New("truncated copy")
+			}
+			size = 4 + int(tag>>2&7)
+			offset = int(tag&0xe0)<<3 | int(src[0])
+			src = src[1:]
+		case 2:
+			if len(src) < 2 {
+				return nil, errors.New("truncated copy")
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src))
+			src = src[2:]
+		case 3:
+			if len(src) < 4 {
+				return nil, errors.New("truncated copy")
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src))
+			src = src[4:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, errors.New("invalid copy offset")
+		}
+		// copies may overlap the bytes they produce, so they go one byte at a time
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
@@ -0,0 +1,95 @@
+package mockgrafana
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// appendProtoField appends a length delimited protobuf field
+func appendProtoField(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|protoBytes))
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+// snappyLiteral encodes b as a snappy block holding a single literal
+func snappyLiteral(b []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(b)))
+	switch n := len(b) - 1; {
+	case n < 60:
+		dst = append(dst, byte(n<<2))
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	default:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	}
+	return append(dst, b...)
+}
+
+func TestSnappyDecode(t *testing.T) {
+	t.Run("should decode literals and copies", func(t *testing.T) {
+		for _, tc := range []struct {
+			src  []byte
+			want string
+		}{
+			{snappyLiteral([]byte("abcd")), "abcd"},
+			{snappyLiteral(bytes.Repeat([]byte("a"), 300)), string(bytes.Repeat([]byte("a"), 300))},
+			// abcd followed by a copy of 8 bytes from 4 back, using a 1 byte offset
+			{[]byte{12, 0x0c, 'a', 'b', 'c', 'd', 0x11, 0x04}, "abcdabcdabcd"},
+			// abcd followed by a copy of 16 bytes from 4 back, using a 2 byte offset
+			{[]byte{20, 0x0c, 'a', 'b', 'c', 'd', 0x3e, 0x04, 0x00}, "abcdabcdabcdabcdabcd"},
+		} {
+			got, err := snappyDecode(tc.src)
+			if err != nil || string(got) != tc.want {
+				t.Errorf("got %q, %v want %q", got, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("should reject corrupt blocks", func(t *testing.T) {
+		for _, src := range [][]byte{
+			{},
+			{5, 0x0c, 'a', 'b'},
+			{8, 0x0c, 'a', 'b', 'c', 'd', 0x11, 0x08},
+			{12, 0x0c, 'a', 'b', 'c', 'd'},
+		} {
+			if _, err := snappyDecode(src); err == nil {
+				t.Errorf("expected error for %v but got none", src)
+			}
+		}
+	})
+}
+
+func TestDecodeMessage(t *testing.T) {
+	t.Run("should decode every wire type", func(t *testing.T) {
+		b := binary.AppendUvarint(nil, 1<<3|protoVarint)
+		b = binary.AppendUvarint(b, 300)
+		b = binary.AppendUvarint(b, 2<<3|protoFixed64)
+		b = binary.LittleEndian.AppendUint64(b, 7)
+		b = appendProtoField(b, 3, []byte("value"))
+		b = binary.AppendUvarint(b, 4<<3|protoFixed32)
+		b = binary.LittleEndian.AppendUint32(b, 9)
+
+		var fields []int
+		err := decodeMessage(b, func(field int, wireType int, value []byte, number uint64) error {
+			fields = append(fields, field)
+			if (field == 1 && number != 300) || (field == 2 && number != 7) || (field == 3 && string(value) != "value") || (field == 4 && number != 9) {
+				t.Errorf("field %d: got %q, %d", field, value, number)
+			}
+			return nil
+		})
+
+		if err != nil || len(fields) != 4 {
+			t.Errorf("got fields %v, %v", fields, err)
+		}
+	})
+
+	t.Run("should reject truncated messages", func(t *testing.T) {
+		b := appendProtoField(nil, 1, []byte("value"))
+
+		if err := decodeMessage(b[:len(b)-1], func(int, int, []byte, uint64) error { return nil }); err == nil {
+			t.Errorf("expected error but got none")
+		}
+	})
+}